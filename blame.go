@@ -0,0 +1,467 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// a commit that changed the workbook
+type blameCommit struct {
+	hash    string
+	author  string
+	date    string
+	summary string
+}
+
+func (commit blameCommit) shortHash() string {
+	return commit.hash[:min(8, len(commit.hash))]
+}
+
+// a version of the workbook in its history. Path is the git path of the
+// workbook in the commit or the local path for the working tree
+type blameVersion struct {
+	commit      blameCommit
+	path        string
+	workingTree bool
+}
+
+// a cell of the newest version and the version that last changed it
+type blameCell struct {
+	cell    string
+	row     int
+	key     string
+	value   string
+	version int
+}
+
+// the cells or rows of the newest version that are inside the range given by the user
+type blameRange struct {
+	firstCol int
+	firstRow int
+	lastCol  int // 0 for no limit
+	lastRow  int // 0 for no limit
+}
+
+func blameUsage() {
+	fmt.Printf("Usage: ged blame [arguments] <excel workbook> [sheet] [range]\n")
+	fmt.Printf("Shows the commit that last changed each cell. Range is a cell, cells (B2:D10) or columns (C or C:D)\n")
+}
+
+// ged blame walks the history of the workbook and finds the commit that last
+// changed each cell, matching rows by primary key like the diff does
+func runBlame(args []string) {
+	flags := flag.NewFlagSet("blame", flag.ExitOnError)
+	var keyFlag = flags.String("k", "", "The primary key to be used for matching rows between versions")
+	var outputFlag = flags.String("o", "", "Path to directory where the output file should go. Default is the current working directory")
+	var rowsFlag = flags.Bool("rows", false, "Show the last change of each row instead of each cell")
+	var verboseFlag = flags.Bool("v", false, "Display verbose output")
+	flags.Usage = func() {
+		blameUsage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	verboseOutput = *verboseFlag
+
+	if flags.NArg() < 1 || flags.NArg() > 3 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	sheetFilter := flags.Arg(1)
+
+	var cellRange blameRange
+	if flags.NArg() == 3 {
+		var err error
+		cellRange, err = parseBlameRange(flags.Arg(2))
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	currentDir, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Unable to find current directory. Aborting..\r\n")
+		panic(err)
+	}
+
+	gitRoot, err := runGit("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		fmt.Printf("Error: Unable to find git root folder: %s\n", err)
+		os.Exit(1)
+	}
+	gitRoot = filepath.FromSlash(gitRoot) + string(filepath.Separator)
+
+	workBookFullPath := absolutePath(currentDir, flags.Arg(0))
+	workBookGitPath := filepath.ToSlash(strings.ReplaceAll(workBookFullPath, gitRoot, ""))
+
+	versions, err := workbookVersions(gitRoot, workBookGitPath, workBookFullPath)
+	if err != nil {
+		fmt.Printf("Error: Unable to read the history of %s: %s\n", workBookGitPath, err)
+		os.Exit(1)
+	}
+
+	if len(versions) == 0 {
+		fmt.Printf("Error: %s is not in the git history\n", workBookGitPath)
+		os.Exit(1)
+	}
+
+	tempDir, err := os.MkdirTemp("", "ged-blame")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	sheets, versionData := readBlameVersions(gitRoot, tempDir, versions, sheetFilter)
+	if len(sheets) == 0 {
+		fmt.Printf("Error: Sheet %s not found in %s\n", sheetFilter, workBookGitPath)
+		os.Exit(1)
+	}
+
+	primaryKeyList := []string{}
+	if *keyFlag != "" {
+		primaryKeyList = append(primaryKeyList, *keyFlag)
+	}
+
+	outputFilePath := filepath.Join(currentDir, filepath.FromSlash(*outputFlag), workbookName(workBookFullPath)+"-blame.html")
+	htmlFile, err := os.Create(outputFilePath)
+	if err != nil {
+		panic(err)
+	}
+	defer htmlFile.Close()
+
+	htmlFile.Write([]byte(htmlAddSubHeading("Blame for " + workBookGitPath)))
+
+	for _, sheet := range sheets {
+		var sheetData [][][]string
+		for _, data := range versionData {
+			sheetData = append(sheetData, data[sheet])
+		}
+
+		cells := blameSheet(sheetData, primaryKeyList)
+		cells = filterBlameCells(cells, cellRange)
+
+		if *rowsFlag {
+			cells = blameRows(cells)
+		}
+
+		fmt.Printf("== Sheet: %s ==\n", sheet)
+		for _, cell := range cells {
+			fmt.Println(blameCellString(cell, versions[cell.version].commit, *rowsFlag))
+		}
+		fmt.Println()
+
+		htmlFile.Write([]byte(htmlAddBlame(sheet, cells, versions, *rowsFlag)))
+	}
+
+	fmt.Printf("Blame written to %s\r\n", outputFilePath)
+}
+
+// lists the commits that changed the workbook, oldest first, following renames
+func workbookHistory(gitRoot string, gitPath string) ([]blameVersion, error) {
+	output, err := runGit(gitRoot, "-c", "core.quotePath=false", "log", "--follow", "-M", "--date=short",
+		"--format=%x00%H%x09%an%x09%ad%x09%s", "--name-only", "--", gitPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []blameVersion
+	for _, entry := range strings.Split(output, "\x00") {
+		lines := strings.Split(strings.TrimSpace(entry), "\n")
+		fields := strings.SplitN(lines[0], "\t", 4)
+		if len(fields) < 4 {
+			continue
+		}
+
+		// merge commits do not list the file so they are skipped
+		path := strings.TrimSpace(lines[len(lines)-1])
+		if len(lines) < 2 || path == "" {
+			continue
+		}
+
+		commit := blameCommit{hash: fields[0], author: fields[1], date: fields[2], summary: fields[3]}
+		versions = append([]blameVersion{{commit: commit, path: path}}, versions...)
+	}
+
+	return versions, nil
+}
+
+// the committed versions of the workbook followed by the working tree file so
+// changes that are not committed yet show up as well
+func workbookVersions(gitRoot string, gitPath string, fullPath string) ([]blameVersion, error) {
+	versions, err := workbookHistory(gitRoot, gitPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(fullPath); err == nil {
+		versions = append(versions, blameVersion{commit: blameCommit{hash: "0000000000000000000000000000000000000000", author: "Not Committed Yet", date: time.Now().Format("2006-01-02")},
+			path: fullPath, workingTree: true})
+	}
+
+	return versions, nil
+}
+
+// reads the sheets of every version. Returns the sheets of the newest version
+// that match the filter and the rows of each sheet in each version
+func readBlameVersions(gitRoot string, tempDir string, versions []blameVersion, sheetFilter string) ([]string, []map[string][][]string) {
+	var versionData []map[string][][]string
+	var sheets []string
+
+	for index, version := range versions {
+		workBook := version.path
+		if !version.workingTree {
+			workBook = filepath.Join(tempDir, strconv.Itoa(index)+filepath.Ext(version.path))
+			if err := writeGitBlob(gitRoot, version.commit.hash, version.path, workBook); err != nil {
+				fmt.Printf("Error: Unable to read %s at %s: %s\n", version.path, version.commit.hash, err)
+				os.Exit(1)
+			}
+		}
+
+		excelFile, err := openWorkbook(workBook)
+		if err != nil {
+			fmt.Printf("Error: Unable to open %s at %s: %s\n", version.path, version.commit.hash, err)
+			os.Exit(1)
+		}
+
+		data := make(map[string][][]string)
+		sheets = nil
+		for _, sheet := range excelFile.GetSheetList() {
+			if sheetFilter == "" || sheet == sheetFilter {
+				data[sheet] = readPaddedRows(excelFile, sheet)
+				sheets = append(sheets, sheet)
+			}
+		}
+		excelFile.Close()
+
+		if verboseOutput {
+			fmt.Printf("Read %s at %s\n", version.path, version.commit.hash)
+		}
+
+		versionData = append(versionData, data)
+	}
+
+	return sheets, versionData
+}
+
+// checks if rows of a version of the sheet can be matched by the primary key.
+// An empty version has no rows to match
+func blameKeyUsable(data [][]string, primaryKeys []string) bool {
+	if len(data) == 0 {
+		return true
+	}
+
+	headerRow := detectHeaderRow(data)
+	keyIndexes := findPrimaryKeyIndexes(data[headerRow], primaryKeys)
+
+	return len(keyIndexes) > 0 && len(keyIndexes) == len(primaryKeys) && primaryKeysUnique(data[headerRow:], keyIndexes) == nil
+}
+
+// gives every cell of a version of the sheet a key so the same cell can be
+// found in the other versions. Data rows are keyed by the primary key when one
+// is given and by position when it is not
+func blameCellKeys(data [][]string, primaryKeys []string) map[string]blameCell {
+	cells := make(map[string]blameCell)
+	if len(data) == 0 {
+		return cells
+	}
+
+	headerRow := detectHeaderRow(data)
+	header := data[headerRow]
+	columnKeys := headerColumnKeys(header)
+
+	var keyIndexes []int
+	if len(primaryKeys) > 0 {
+		keyIndexes = findPrimaryKeyIndexes(header, primaryKeys)
+	}
+
+	for rowIndex, row := range data {
+		rowKey := "row " + strconv.Itoa(rowIndex)
+		displayKey := ""
+		if rowIndex > headerRow && keyIndexes != nil {
+			rowKey = "key " + concatKeysData(row, keyIndexes)
+
+			var parts []string
+			for _, index := range keyIndexes {
+				parts = append(parts, header[index]+"="+row[index])
+			}
+			displayKey = strings.Join(parts, ", ")
+		}
+
+		for col, value := range row {
+			cellKey := rowKey + "%@!#!@%" + strconv.Itoa(col)
+			if rowIndex > headerRow {
+				cellKey = rowKey + "%@!#!@%" + columnKeys[col]
+			}
+
+			cellName, err := excelize.CoordinatesToCellName(col+1, rowIndex+1)
+			if err != nil {
+				panic(err)
+			}
+
+			cells[cellKey] = blameCell{cell: cellName, row: rowIndex + 1, key: displayKey, value: value}
+		}
+	}
+
+	return cells
+}
+
+// finds the version that last changed each non empty cell of the newest version
+func blameSheet(versions [][][]string, primaryKeys []string) []blameCell {
+	newest := versions[len(versions)-1]
+
+	if len(primaryKeys) == 0 && len(newest) > 0 {
+		headerRow := detectHeaderRow(newest)
+		primaryKeys = autoFindPrimaryKeyNames(newest[headerRow:], newest[headerRow:])
+		if verboseOutput {
+			fmt.Printf("Primary key %s found\n", primaryKeys)
+		}
+	}
+
+	// row keys never match primary key keys, so every version is keyed the same
+	// way or cells would be blamed on the version the key became usable in
+	for _, data := range versions {
+		if len(primaryKeys) > 0 && !blameKeyUsable(data, primaryKeys) {
+			fmt.Printf("Primary key %s is missing or not unique in some versions. Matching rows by position\n", primaryKeys)
+			primaryKeys = nil
+		}
+	}
+
+	blamed := make(map[string]int)
+	previous := make(map[string]blameCell)
+
+	var current map[string]blameCell
+	for index, data := range versions {
+		current = blameCellKeys(data, primaryKeys)
+
+		for key, cell := range current {
+			if previousCell, ok := previous[key]; !ok || previousCell.value != cell.value {
+				blamed[key] = index
+			}
+		}
+
+		previous = current
+	}
+
+	var cells []blameCell
+	for key, cell := range current {
+		if cell.value == "" {
+			continue
+		}
+		cell.version = blamed[key]
+		cells = append(cells, cell)
+	}
+
+	sortBlameCells(cells)
+
+	return cells
+}
+
+func sortBlameCells(cells []blameCell) {
+	sort.SliceStable(cells, func(i, j int) bool {
+		firstCol, firstRow, _ := excelize.CellNameToCoordinates(cells[i].cell)
+		secondCol, secondRow, _ := excelize.CellNameToCoordinates(cells[j].cell)
+		if firstRow != secondRow {
+			return firstRow < secondRow
+		}
+		return firstCol < secondCol
+	})
+}
+
+// the last change of each row is the newest change of any of its cells
+func blameRows(cells []blameCell) []blameCell {
+	var rows []blameCell
+
+	for _, cell := range cells {
+		if len(rows) > 0 && rows[len(rows)-1].row == cell.row {
+			rows[len(rows)-1].version = max(rows[len(rows)-1].version, cell.version)
+			rows[len(rows)-1].value += ", " + cell.value
+			continue
+		}
+
+		cell.cell = strconv.Itoa(cell.row)
+		rows = append(rows, cell)
+	}
+
+	return rows
+}
+
+// parses a range of cells like B2:D10, a cell like B2 or columns like C or C:D
+func parseBlameRange(spec string) (blameRange, error) {
+	first, last, found := strings.Cut(strings.ToUpper(spec), ":")
+	if !found {
+		last = first
+	}
+
+	var cellRange blameRange
+	var err error
+
+	cellRange.firstCol, cellRange.firstRow, err = parseBlameRangeCell(first)
+	if err != nil {
+		return cellRange, fmt.Errorf("invalid range %s: %w", spec, err)
+	}
+
+	cellRange.lastCol, cellRange.lastRow, err = parseBlameRangeCell(last)
+	if err != nil {
+		return cellRange, fmt.Errorf("invalid range %s: %w", spec, err)
+	}
+
+	return cellRange, nil
+}
+
+// parses a cell or a column, the row is 0 for a column
+func parseBlameRangeCell(cell string) (int, int, error) {
+	if strings.Trim(cell, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == "" {
+		col, err := excelize.ColumnNameToNumber(cell)
+		return col, 0, err
+	}
+
+	return excelize.CellNameToCoordinates(cell)
+}
+
+func filterBlameCells(cells []blameCell, cellRange blameRange) []blameCell {
+	if cellRange == (blameRange{}) {
+		return cells
+	}
+
+	var filtered []blameCell
+	for _, cell := range cells {
+		col, row, err := excelize.CellNameToCoordinates(cell.cell)
+		if err != nil {
+			continue
+		}
+
+		if col < cellRange.firstCol || (cellRange.lastCol > 0 && col > cellRange.lastCol) {
+			continue
+		}
+		if (cellRange.firstRow > 0 && row < cellRange.firstRow) || (cellRange.lastRow > 0 && row > cellRange.lastRow) {
+			continue
+		}
+
+		filtered = append(filtered, cell)
+	}
+
+	return filtered
+}
+
+// formats a cell like git blame formats a line
+func blameCellString(cell blameCell, commit blameCommit, rows bool) string {
+	location := cell.cell
+	if rows {
+		location = "row " + cell.cell
+	}
+	if cell.key != "" {
+		location += " (" + cell.key + ")"
+	}
+
+	return fmt.Sprintf("%s (%-20s %s) %s: %s", commit.shortHash(), commit.author, commit.date, location, textconvValue(cell.value))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBlameSheet(t *testing.T) {
+	// the cells of the newest version as cell name and the version that last changed it
+	type blamed struct {
+		cell    string
+		version int
+	}
+
+	tests := []struct {
+		name        string
+		versions    [][][]string
+		primaryKeys []string
+		cells       []blamed
+	}{
+		{
+			name: "rows matched by key",
+			versions: [][][]string{
+				{{"ID", "Name"}, {"1", "a"}, {"2", "b"}},
+				{{"ID", "Name"}, {"2", "bb"}, {"1", "a"}},
+			},
+			primaryKeys: []string{"ID"},
+			cells:       []blamed{{"A1", 0}, {"B1", 0}, {"A2", 0}, {"B2", 1}, {"A3", 0}, {"B3", 0}},
+		},
+		{
+			name: "row added and cell changed",
+			versions: [][][]string{
+				{{"ID", "Name"}, {"1", "a"}},
+				{{"ID", "Name"}, {"1", "a"}, {"2", "b"}},
+				{{"ID", "Name"}, {"1", "aa"}, {"2", "b"}},
+			},
+			primaryKeys: []string{"ID"},
+			cells:       []blamed{{"A1", 0}, {"B1", 0}, {"A2", 0}, {"B2", 2}, {"A3", 1}, {"B3", 1}},
+		},
+		{
+			// the key is not unique in the first version so every version is matched by position
+			name: "key not unique in an older version",
+			versions: [][][]string{
+				{{"ID", "Name"}, {"1", "a"}, {"1", "b"}},
+				{{"ID", "Name"}, {"1", "a"}, {"2", "b"}},
+				{{"ID", "Name"}, {"1", "a"}, {"2", "b"}, {"3", "c"}},
+			},
+			primaryKeys: []string{"ID"},
+			cells:       []blamed{{"A1", 0}, {"B1", 0}, {"A2", 0}, {"B2", 0}, {"A3", 1}, {"B3", 0}, {"A4", 2}, {"B4", 2}},
+		},
+		{
+			name: "sheet added in a later version",
+			versions: [][][]string{
+				{},
+				{{"ID", "Name"}, {"1", "a"}},
+				{{"ID", "Name"}, {"1", "b"}},
+			},
+			primaryKeys: []string{"ID"},
+			cells:       []blamed{{"A1", 1}, {"B1", 1}, {"A2", 1}, {"B2", 2}},
+		},
+		{
+			name: "empty cells are not blamed",
+			versions: [][][]string{
+				{{"ID", "Name"}, {"1", "a"}},
+				{{"ID", "Name"}, {"1", ""}},
+			},
+			primaryKeys: []string{"ID"},
+			cells:       []blamed{{"A1", 0}, {"B1", 0}, {"A2", 0}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var cells []blamed
+			for _, cell := range blameSheet(test.versions, test.primaryKeys) {
+				cells = append(cells, blamed{cell.cell, cell.version})
+			}
+
+			if !reflect.DeepEqual(cells, test.cells) {
+				t.Errorf("cells %v, want %v", cells, test.cells)
+			}
+		})
+	}
+}
+
+func TestBlameCellKeys(t *testing.T) {
+	data := [][]string{{"ID", "Name", "Name"}, {"7", "a", "b"}}
+
+	columnKeys := headerColumnKeys(data[0])
+
+	keyed := blameCellKeys(data, []string{"ID"})
+	if cell, ok := keyed["key "+concatKeysData(data[1], []int{0})+"%@!#!@%"+columnKeys[2]]; !ok || cell.cell != "C2" || cell.key != "ID=7" {
+		t.Errorf("keyed cell %+v, want C2 with key ID=7", cell)
+	}
+
+	byRow := blameCellKeys(data, nil)
+	if cell, ok := byRow["row 1%@!#!@%"+columnKeys[1]]; !ok || cell.cell != "B2" || cell.key != "" {
+		t.Errorf("row cell %+v, want B2 without a key", cell)
+	}
+}
+
+func TestParseBlameRange(t *testing.T) {
+	tests := []struct {
+		spec      string
+		cellRange blameRange
+		err       bool
+	}{
+		{spec: "B2", cellRange: blameRange{firstCol: 2, firstRow: 2, lastCol: 2, lastRow: 2}},
+		{spec: "b2:d10", cellRange: blameRange{firstCol: 2, firstRow: 2, lastCol: 4, lastRow: 10}},
+		{spec: "C", cellRange: blameRange{firstCol: 3, lastCol: 3}},
+		{spec: "C:AA", cellRange: blameRange{firstCol: 3, lastCol: 27}},
+		{spec: "2:3", err: true},
+		{spec: "B2:", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			cellRange, err := parseBlameRange(test.spec)
+			if test.err {
+				if err == nil {
+					t.Errorf("got %+v, want an error", cellRange)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if cellRange != test.cellRange {
+				t.Errorf("got %+v, want %+v", cellRange, test.cellRange)
+			}
+		})
+	}
+}
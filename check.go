@@ -0,0 +1,469 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// the rules file ged check reads from the root of the repo
+const checkConfigFileName = "gedCheck.json"
+
+// the most changes to a protected column listed before the rest are counted
+const maxProtectedChanges = 10
+
+type checkConfig struct {
+	Workbooks []workbookRules `json:"workbooks"`
+}
+
+// the rules for the workbooks whose git path matches the pattern
+type workbookRules struct {
+	Pattern        string                `json:"pattern"`
+	RequiredSheets []string              `json:"requiredSheets"`
+	Sheets         map[string]sheetRules `json:"sheets"`
+}
+
+type sheetRules struct {
+	PrimaryKey       []string `json:"primaryKey"`
+	RequiredColumns  []string `json:"requiredColumns"`
+	ProtectedColumns []string `json:"protectedColumns"`
+}
+
+// the problems found in a sheet, sheet is blank for problems with the workbook
+type checkFailure struct {
+	sheet   string
+	message string
+}
+
+func checkUsage() {
+	fmt.Printf("Usage: ged check [arguments] [excel workbooks]\n")
+	fmt.Printf("Checks workbooks against the rules in %s at the root of the repo\n", checkConfigFileName)
+}
+
+// ged check validates workbooks against the rules of the repo and exits with 1
+// if any rule fails so it can block a commit
+func runCheck(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	var stagedFlag = flags.Bool("staged", false, "Check the staged version of every staged workbook")
+	var verboseFlag = flags.Bool("v", false, "Display verbose output")
+	flags.Usage = func() {
+		checkUsage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	verboseOutput = *verboseFlag
+
+	if *stagedFlag == (flags.NArg() > 0) {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	currentDir, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Unable to find current directory. Aborting..\r\n")
+		panic(err)
+	}
+
+	gitRoot, err := runGit("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		fmt.Printf("Error: Unable to find git root folder: %s\n", err)
+		os.Exit(1)
+	}
+	gitRoot = filepath.FromSlash(gitRoot) + string(filepath.Separator)
+
+	// a commit is checked against the rules it will be committed with
+	configRef := ""
+	if *stagedFlag {
+		configRef = indexRef
+	}
+
+	config, err := readCheckConfig(gitRoot, configRef)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("No %s found, nothing to check\n", checkConfigFileName)
+		return
+	} else if err != nil {
+		fmt.Printf("Error: Unable to read %s: %s\n", checkConfigFileName, err)
+		os.Exit(1)
+	}
+
+	var gitPaths []string
+	if *stagedFlag {
+		args := append([]string{"-c", "core.quotePath=false", "diff", "--cached", "--name-only", "--diff-filter=ACMR", "--"}, workbookPathspecs...)
+		output, err := runGit(gitRoot, args...)
+		if err != nil {
+			fmt.Printf("Error: Unable to list staged files: %s\n", err)
+			os.Exit(1)
+		}
+		if output != "" {
+			gitPaths = strings.Split(output, "\n")
+		}
+	} else {
+		for _, arg := range flags.Args() {
+			gitPaths = append(gitPaths, filepath.ToSlash(strings.ReplaceAll(absolutePath(currentDir, arg), gitRoot, "")))
+		}
+	}
+
+	tempDir, err := os.MkdirTemp("", "ged-check")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	failed := false
+	checked := 0
+
+	for index, gitPath := range gitPaths {
+		rules := config.rulesFor(gitPath)
+		if len(rules) == 0 {
+			continue
+		}
+		checked++
+
+		ext := filepath.Ext(gitPath)
+
+		// the staged version is checked for a commit, the working tree file otherwise
+		workBook := filepath.Join(gitRoot, filepath.FromSlash(gitPath))
+		if *stagedFlag {
+			workBook = filepath.Join(tempDir, fmt.Sprintf("%d-Staged%s", index, ext))
+			if err := writeGitBlob(gitRoot, indexRef, gitPath, workBook); err != nil {
+				fmt.Printf("Error: Unable to read staged %s: %s\n", gitPath, err)
+				os.Exit(1)
+			}
+		}
+
+		// protected columns are compared with HEAD, following renames
+		headPath := gitPath
+		checkedRef := ""
+		if *stagedFlag {
+			checkedRef = indexRef
+		}
+		if renamedFrom := findRenamedPath(gitRoot, "HEAD", checkedRef, gitPath); renamedFrom != "" {
+			headPath = renamedFrom
+		}
+
+		headWorkBook := ""
+		if gitPathExists(gitRoot, "HEAD", headPath) {
+			headWorkBook = filepath.Join(tempDir, fmt.Sprintf("%d-Head%s", index, ext))
+			if err := writeGitBlob(gitRoot, "HEAD", headPath, headWorkBook); err != nil {
+				fmt.Printf("Error: Unable to read %s in HEAD: %s\n", headPath, err)
+				os.Exit(1)
+			}
+		}
+
+		failures := checkWorkbook(workBook, headWorkBook, rules)
+		if len(failures) == 0 {
+			if verboseOutput {
+				fmt.Printf("%s: ok\n", gitPath)
+			}
+			continue
+		}
+
+		failed = true
+		fmt.Print(checkReport(gitPath, failures))
+	}
+
+	if failed {
+		fmt.Printf("ged check failed. Fix the workbooks above or commit with --no-verify to skip the check\n")
+		os.Exit(1)
+	}
+
+	fmt.Printf("ged check passed for %d workbooks\n", checked)
+}
+
+// reads the rules file from the working tree or, when ref is set, from the
+// commit or the index
+func readCheckConfig(gitRoot string, ref string) (checkConfig, error) {
+	var config checkConfig
+	var configBytes []byte
+
+	if ref == "" {
+		fileBytes, err := os.ReadFile(filepath.Join(gitRoot, checkConfigFileName))
+		if err != nil {
+			return config, err
+		}
+		configBytes = fileBytes
+	} else {
+		if !gitPathExists(gitRoot, ref, checkConfigFileName) {
+			return config, os.ErrNotExist
+		}
+		output, err := runGit(gitRoot, "show", gitObject(ref, checkConfigFileName))
+		if err != nil {
+			return config, err
+		}
+		configBytes = []byte(output)
+	}
+
+	decoder := json.NewDecoder(bytes.NewBuffer(configBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&config)
+
+	return config, err
+}
+
+// the rules whose pattern matches the git path of the workbook. Patterns
+// without a slash match the file name in any directory
+func (config checkConfig) rulesFor(gitPath string) []workbookRules {
+	var rules []workbookRules
+
+	for _, workbook := range config.Workbooks {
+		name := gitPath
+		if !strings.Contains(workbook.Pattern, "/") {
+			name = path.Base(gitPath)
+		}
+
+		if matched, err := path.Match(workbook.Pattern, name); err == nil && matched {
+			rules = append(rules, workbook)
+		}
+	}
+
+	return rules
+}
+
+// checks the workbook against the rules. headWorkBook is the version in HEAD
+// for protected columns, blank when the workbook is new
+func checkWorkbook(workBook string, headWorkBook string, rules []workbookRules) []checkFailure {
+	excelFile, err := openWorkbook(workBook)
+	if err != nil {
+		return []checkFailure{{message: "unable to open workbook: " + err.Error()}}
+	}
+	defer excelFile.Close()
+
+	var excelHead *excelize.File
+	if headWorkBook != "" {
+		excelHead, err = openWorkbook(headWorkBook)
+		if err != nil {
+			return []checkFailure{{message: "unable to open the version in HEAD: " + err.Error()}}
+		}
+		defer excelHead.Close()
+	}
+
+	var failures []checkFailure
+	sheets := excelFile.GetSheetList()
+
+	for _, workbook := range rules {
+		for _, sheet := range workbook.RequiredSheets {
+			if !containsString(sheets, sheet) {
+				failures = append(failures, checkFailure{sheet: sheet, message: "required sheet is missing"})
+			}
+		}
+
+		var ruleSheets []string
+		for sheet := range workbook.Sheets {
+			ruleSheets = append(ruleSheets, sheet)
+		}
+		sort.Strings(ruleSheets)
+
+		for _, sheet := range ruleSheets {
+			if !containsString(sheets, sheet) {
+				continue
+			}
+			sheetRule := workbook.Sheets[sheet]
+
+			var headData [][]string
+			if excelHead != nil && containsString(excelHead.GetSheetList(), sheet) {
+				headData = readPaddedRows(excelHead, sheet)
+			}
+
+			for _, message := range checkSheet(readPaddedRows(excelFile, sheet), headData, sheetRule) {
+				failures = append(failures, checkFailure{sheet: sheet, message: message})
+			}
+		}
+	}
+
+	return failures
+}
+
+// checks the rules of one sheet and returns a message for each one that fails
+func checkSheet(data [][]string, headData [][]string, rules sheetRules) []string {
+	var messages []string
+
+	if len(data) == 0 {
+		return []string{"sheet is empty"}
+	}
+
+	headerRow := detectHeaderRow(data)
+	header := data[headerRow]
+
+	for _, column := range rules.RequiredColumns {
+		if !containsString(header, column) {
+			messages = append(messages, "required column "+column+" is missing")
+		}
+	}
+
+	if len(rules.PrimaryKey) > 0 {
+		keyIndexes := findPrimaryKeyIndexes(header, rules.PrimaryKey)
+		if len(keyIndexes) != len(rules.PrimaryKey) {
+			messages = append(messages, fmt.Sprintf("primary key %v columns are missing", rules.PrimaryKey))
+		} else if err := primaryKeysUnique(data[headerRow+1:], keyIndexes); err != nil {
+			messages = append(messages, fmt.Sprintf("primary key %v is not unique: %s", rules.PrimaryKey, readableKeyError(err)))
+		}
+	}
+
+	if len(rules.ProtectedColumns) > 0 && len(headData) > 0 {
+		messages = append(messages, checkProtectedColumns(data, headData, rules)...)
+	}
+
+	return messages
+}
+
+// lists the cells of protected columns that are different from HEAD. Rows are
+// matched by the primary key when it is unique in both versions and with the
+// same sequence diff as the compare when it is not
+func checkProtectedColumns(data [][]string, headData [][]string, rules sheetRules) []string {
+	var messages []string
+
+	headerRow := detectHeaderRow(data)
+	header := data[headerRow]
+	headHeaderRow := detectHeaderRow(headData)
+	headHeader := headData[headHeaderRow]
+
+	rows := data[headerRow+1:]
+	headRows := headData[headHeaderRow+1:]
+
+	keyIndexes := findPrimaryKeyIndexes(header, rules.PrimaryKey)
+	headKeyIndexes := findPrimaryKeyIndexes(headHeader, rules.PrimaryKey)
+	keyed := len(rules.PrimaryKey) > 0 && len(keyIndexes) == len(rules.PrimaryKey) && len(headKeyIndexes) == len(rules.PrimaryKey) &&
+		primaryKeysUnique(rows, keyIndexes) == nil && primaryKeysUnique(headRows, headKeyIndexes) == nil
+
+	// the row in HEAD for each row index. Unchanged rows are left out as
+	// their protected cells can't be different
+	matchedRows := make(map[int][]string)
+	if keyed {
+		headKeyed := make(map[string][]string)
+		for _, row := range headRows {
+			headKeyed[concatKeysData(row, headKeyIndexes)] = row
+		}
+		for index, row := range rows {
+			if headRow, ok := headKeyed[concatKeysData(row, keyIndexes)]; ok {
+				matchedRows[index] = headRow
+			}
+		}
+	} else {
+		for _, hunk := range sequenceDiffHunks(headRows, rows, nil, nil) {
+			for _, line := range hunk.lines {
+				if line.lineType == difference {
+					matchedRows[line.minePos] = headRows[line.theirsPos]
+				}
+			}
+		}
+	}
+
+	for _, column := range rules.ProtectedColumns {
+		col := slices.Index(header, column)
+		headCol := slices.Index(headHeader, column)
+
+		if headCol < 0 {
+			continue
+		}
+		if col < 0 {
+			messages = append(messages, "protected column "+column+" was removed")
+			continue
+		}
+
+		var changes []string
+		for index, row := range rows {
+			headRow, ok := matchedRows[index]
+			if !ok || cellAt(headRow, headCol) == cellAt(row, col) {
+				continue
+			}
+
+			cellName, _ := excelize.CoordinatesToCellName(col+1, headerRow+index+2)
+			location := cellName
+			if keyed {
+				location += " (" + protectedRowKey(header, row, keyIndexes) + ")"
+			}
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", location, cellAt(headRow, headCol), cellAt(row, col)))
+		}
+
+		if len(changes) == 0 {
+			continue
+		}
+
+		message := "protected column " + column + " changed"
+		for index, change := range changes {
+			if index == maxProtectedChanges {
+				message += fmt.Sprintf("\n        and %d more", len(changes)-maxProtectedChanges)
+				break
+			}
+			message += "\n        " + change
+		}
+		messages = append(messages, message)
+	}
+
+	return messages
+}
+
+func protectedRowKey(header []string, row []string, keyIndexes []int) string {
+	var parts []string
+	for _, index := range keyIndexes {
+		parts = append(parts, header[index]+"="+row[index])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// groups the failures of a workbook by sheet
+func checkReport(gitPath string, failures []checkFailure) string {
+	report := gitPath + "\n"
+
+	var sheets []string
+	bySheet := make(map[string][]string)
+	for _, failure := range failures {
+		if _, ok := bySheet[failure.sheet]; !ok {
+			sheets = append(sheets, failure.sheet)
+		}
+		bySheet[failure.sheet] = append(bySheet[failure.sheet], failure.message)
+	}
+
+	for _, sheet := range sheets {
+		indent := "  "
+		if sheet != "" {
+			report += "  " + sheet + "\n"
+			indent = "    "
+		}
+		for _, message := range bySheet[sheet] {
+			report += indent + message + "\n"
+		}
+	}
+
+	return report + "\n"
+}
+
+func hookUsage() {
+	fmt.Printf("Usage: ged hook install [-f]\n")
+	fmt.Printf("Installs a git pre-commit hook that runs ged check --staged\n")
+}
+
+// ged hook install sets up the pre-commit hook of the repo to run ged check
+func runHook(args []string) {
+	flags := flag.NewFlagSet("hook", flag.ExitOnError)
+	var forceFlag = flags.Bool("f", false, "Replace an existing pre-commit hook")
+	flags.Usage = func() {
+		hookUsage()
+		flags.PrintDefaults()
+	}
+
+	if len(args) == 0 || args[0] != "install" {
+		flags.Usage()
+		os.Exit(1)
+	}
+	flags.Parse(args[1:])
+
+	hookPath, err := installPreCommitHook(*forceFlag)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Pre-commit hook installed at %s. Add rules to %s at the root of the repo\n", hookPath, checkConfigFileName)
+}
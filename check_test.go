@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCheckProtectedColumns(t *testing.T) {
+	head := [][]string{{"ID", "Name", "Cost"}, {"1", "a", "5"}, {"2", "b", "6"}, {"3", "c", "7"}}
+
+	tests := []struct {
+		name     string
+		data     [][]string
+		rules    sheetRules
+		messages []string
+	}{
+		{
+			name:  "keyed row moved",
+			data:  [][]string{{"ID", "Name", "Cost"}, {"3", "c", "7"}, {"1", "a", "5"}, {"2", "b", "60"}},
+			rules: sheetRules{PrimaryKey: []string{"ID"}, ProtectedColumns: []string{"Cost"}},
+			messages: []string{
+				"protected column Cost changed\n        C4 (ID=2): \"6\" -> \"60\"",
+			},
+		},
+		{
+			// an inserted row does not shift the rows below it
+			name:     "row inserted without a key",
+			data:     [][]string{{"ID", "Name", "Cost"}, {"1", "a", "5"}, {"4", "new", "1"}, {"2", "b", "6"}, {"3", "c", "7"}},
+			rules:    sheetRules{ProtectedColumns: []string{"Cost"}},
+			messages: nil,
+		},
+		{
+			name:  "row removed and changed without a key",
+			data:  [][]string{{"ID", "Name", "Cost"}, {"2", "b", "6"}, {"3", "c", "70"}},
+			rules: sheetRules{ProtectedColumns: []string{"Cost"}},
+			messages: []string{
+				"protected column Cost changed\n        C3: \"7\" -> \"70\"",
+			},
+		},
+		{
+			name:     "protected column removed",
+			data:     [][]string{{"ID", "Name"}, {"1", "a"}, {"2", "b"}, {"3", "c"}},
+			rules:    sheetRules{ProtectedColumns: []string{"Cost"}},
+			messages: []string{"protected column Cost was removed"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if messages := checkProtectedColumns(test.data, head, test.rules); !reflect.DeepEqual(messages, test.messages) {
+				t.Errorf("messages %q, want %q", messages, test.messages)
+			}
+		})
+	}
+}
+
+func TestReadCheckConfig(t *testing.T) {
+	dir := newTestRepo(t)
+
+	if _, err := readCheckConfig(dir, indexRef); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("error %v, want os.ErrNotExist", err)
+	}
+
+	writeTestFile(t, dir, checkConfigFileName, `{"workbooks": [{"pattern": "staged.xlsx"}]}`)
+	testGit(t, dir, "add", checkConfigFileName)
+	writeTestFile(t, dir, checkConfigFileName, `{"workbooks": [{"pattern": "working.xlsx"}]}`)
+
+	tests := []struct {
+		name    string
+		ref     string
+		pattern string
+	}{
+		{name: "working tree", ref: "", pattern: "working.xlsx"},
+		{name: "index", ref: indexRef, pattern: "staged.xlsx"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := readCheckConfig(dir, test.ref)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(config.Workbooks) != 1 || config.Workbooks[0].Pattern != test.pattern {
+				t.Errorf("workbooks %+v, want pattern %s", config.Workbooks, test.pattern)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type columnChangeType int
+
+const (
+	columnAdded columnChangeType = iota
+	columnRemoved
+	columnMoved
+	columnRenamed
+)
+
+type columnChange struct {
+	name       string
+	oldName    string
+	changeType columnChangeType
+	theirsCol  int // index of the column in theirs, -1 if it is not in theirs
+	mineCol    int // index of the column in mine, -1 if it is not in mine
+}
+
+// maps the columns of the aligned data back to the columns in each sheet
+type columnAlignment struct {
+	theirsCols []int
+	mineCols   []int
+	changes    []columnChange
+}
+
+// gives each header a name that is unique even when the header repeats
+func headerColumnKeys(header []string) []string {
+	var keys []string
+	seen := make(map[string]int)
+
+	for _, name := range header {
+		seen[name]++
+		keys = append(keys, name+"%@!#!@%"+strconv.Itoa(seen[name]))
+	}
+
+	return keys
+}
+
+func sequentialColumns(count int) []int {
+	var cols []int
+	for index := 0; index < count; index++ {
+		cols = append(cols, index)
+	}
+	return cols
+}
+
+// lines the columns of theirs up with the columns of mine using the header row.
+// Both sheets are returned with only the matched columns in mine's order.
+// Columns that were added, removed, moved or renamed are listed in the alignment.
+// If no header names match the columns are lined up by position instead
+func alignColumns(dataTheirs, dataMine [][]string) ([][]string, [][]string, columnAlignment) {
+	var alignment columnAlignment
+
+	if len(dataTheirs) == 0 || len(dataMine) == 0 {
+		width := 0
+		if len(dataTheirs) > 0 {
+			width = len(dataTheirs[0])
+		}
+		if len(dataMine) > 0 {
+			width = len(dataMine[0])
+		}
+		alignment.theirsCols = sequentialColumns(width)
+		alignment.mineCols = sequentialColumns(width)
+		return dataTheirs, dataMine, alignment
+	}
+
+	theirsHeader := dataTheirs[0]
+	mineHeader := dataMine[0]
+	theirsKeys := headerColumnKeys(theirsHeader)
+	mineKeys := headerColumnKeys(mineHeader)
+
+	theirsIndexes := make(map[string]int)
+	for index, key := range theirsKeys {
+		theirsIndexes[key] = index
+	}
+
+	matchedMine := make(map[int]int)
+	matchedTheirs := make(map[int]bool)
+	for mineIndex, key := range mineKeys {
+		if theirsIndex, ok := theirsIndexes[key]; ok {
+			matchedMine[mineIndex] = theirsIndex
+			matchedTheirs[theirsIndex] = true
+		}
+	}
+
+	if len(matchedMine) == 0 {
+		if verboseOutput {
+			fmt.Printf("No matching headers, lining up columns by position\n")
+		}
+		dataTheirs, dataMine = normalizeData(dataTheirs, dataMine)
+		alignment.theirsCols = sequentialColumns(len(dataMine[0]))
+		alignment.mineCols = sequentialColumns(len(dataMine[0]))
+		return dataTheirs, dataMine, alignment
+	}
+
+	var deleted []int
+	var inserted []int
+
+	// unmatched columns in the same gap between unchanged columns are renames
+	flushGap := func() {
+		var gapDeleted []int
+		for _, theirsIndex := range deleted {
+			if !matchedTheirs[theirsIndex] {
+				gapDeleted = append(gapDeleted, theirsIndex)
+			}
+		}
+
+		var gapInserted []int
+		for _, mineIndex := range inserted {
+			if _, ok := matchedMine[mineIndex]; !ok {
+				gapInserted = append(gapInserted, mineIndex)
+			}
+		}
+
+		paired := min(len(gapDeleted), len(gapInserted))
+		for index := 0; index < paired; index++ {
+			matchedMine[gapInserted[index]] = gapDeleted[index]
+			matchedTheirs[gapDeleted[index]] = true
+			alignment.changes = append(alignment.changes, columnChange{name: mineHeader[gapInserted[index]], oldName: theirsHeader[gapDeleted[index]], changeType: columnRenamed, theirsCol: gapDeleted[index], mineCol: gapInserted[index]})
+		}
+
+		for _, theirsIndex := range gapDeleted[paired:] {
+			alignment.changes = append(alignment.changes, columnChange{name: theirsHeader[theirsIndex], changeType: columnRemoved, theirsCol: theirsIndex, mineCol: -1})
+		}
+
+		for _, mineIndex := range gapInserted[paired:] {
+			alignment.changes = append(alignment.changes, columnChange{name: mineHeader[mineIndex], changeType: columnAdded, theirsCol: -1, mineCol: mineIndex})
+		}
+
+		deleted = nil
+		inserted = nil
+	}
+
+	for _, e := range myersDiff(theirsKeys, mineKeys) {
+		switch e.operation {
+		case editEqual:
+			flushGap()
+		case editDelete:
+			deleted = append(deleted, e.theirsPos)
+		case editInsert:
+			// matched by name but not in the same order
+			if theirsIndex, ok := matchedMine[e.minePos]; ok {
+				alignment.changes = append(alignment.changes, columnChange{name: mineHeader[e.minePos], changeType: columnMoved, theirsCol: theirsIndex, mineCol: e.minePos})
+			}
+			inserted = append(inserted, e.minePos)
+		}
+	}
+	flushGap()
+
+	sort.SliceStable(alignment.changes, func(i, j int) bool {
+		return changeSortPos(alignment.changes[i]) < changeSortPos(alignment.changes[j])
+	})
+
+	for mineIndex := range mineHeader {
+		if theirsIndex, ok := matchedMine[mineIndex]; ok {
+			alignment.mineCols = append(alignment.mineCols, mineIndex)
+			alignment.theirsCols = append(alignment.theirsCols, theirsIndex)
+		}
+	}
+
+	return selectColumns(dataTheirs, alignment.theirsCols), selectColumns(dataMine, alignment.mineCols), alignment
+}
+
+// moves the column indexes from the region data to the columns of the sheets
+func offsetColumns(alignment columnAlignment, theirsOffset, mineOffset int) columnAlignment {
+	for index := range alignment.theirsCols {
+		alignment.theirsCols[index] += theirsOffset
+		alignment.mineCols[index] += mineOffset
+	}
+
+	for index := range alignment.changes {
+		if alignment.changes[index].theirsCol >= 0 {
+			alignment.changes[index].theirsCol += theirsOffset
+		}
+		if alignment.changes[index].mineCol >= 0 {
+			alignment.changes[index].mineCol += mineOffset
+		}
+	}
+
+	return alignment
+}
+
+func changeSortPos(change columnChange) int {
+	if change.mineCol >= 0 {
+		return change.mineCol
+	}
+	return change.theirsCol
+}
+
+func selectColumns(data [][]string, cols []int) [][]string {
+	var selected [][]string
+
+	for _, row := range data {
+		var selectedRow []string
+		for _, col := range cols {
+			selectedRow = append(selectedRow, cellAt(row, col))
+		}
+		selected = append(selected, selectedRow)
+	}
+
+	return selected
+}
+
+func columnChangeString(change columnChange) string {
+	switch change.changeType {
+	case columnAdded:
+		return "Column added"
+	case columnRemoved:
+		return "Column removed"
+	case columnMoved:
+		return "Column moved"
+	default:
+		return "Column renamed"
+	}
+}
+
+// spreadsheet letter for a zero based column index, blank if there is no column
+func columnLetter(index int) string {
+	if index < 0 {
+		return ""
+	}
+	name, err := excelize.ColumnNumberToName(index + 1)
+	if err != nil {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAlignColumns(t *testing.T) {
+	tests := []struct {
+		name       string
+		theirs     []string
+		mine       []string
+		theirsCols []int
+		mineCols   []int
+		changes    []columnChange
+	}{
+		{
+			name:       "same columns",
+			theirs:     []string{"ID", "Name", "Qty"},
+			mine:       []string{"ID", "Name", "Qty"},
+			theirsCols: []int{0, 1, 2},
+			mineCols:   []int{0, 1, 2},
+		},
+		{
+			name:       "reorder",
+			theirs:     []string{"ID", "Name", "Qty"},
+			mine:       []string{"ID", "Qty", "Name"},
+			theirsCols: []int{0, 2, 1},
+			mineCols:   []int{0, 1, 2},
+			changes:    []columnChange{{name: "Name", changeType: columnMoved, theirsCol: 1, mineCol: 2}},
+		},
+		{
+			name:       "insert and rename in the same gap",
+			theirs:     []string{"ID", "Name", "Qty"},
+			mine:       []string{"ID", "Title", "Note", "Qty"},
+			theirsCols: []int{0, 1, 2},
+			mineCols:   []int{0, 1, 3},
+			changes: []columnChange{
+				{name: "Title", oldName: "Name", changeType: columnRenamed, theirsCol: 1, mineCol: 1},
+				{name: "Note", changeType: columnAdded, theirsCol: -1, mineCol: 2},
+			},
+		},
+		{
+			name:       "removed column",
+			theirs:     []string{"ID", "Name", "Qty"},
+			mine:       []string{"ID", "Qty"},
+			theirsCols: []int{0, 2},
+			mineCols:   []int{0, 1},
+			changes:    []columnChange{{name: "Name", changeType: columnRemoved, theirsCol: 1, mineCol: -1}},
+		},
+		{
+			// repeated names are matched by occurrence
+			name:       "duplicate header names",
+			theirs:     []string{"ID", "Val", "Val", "Qty"},
+			mine:       []string{"ID", "Val", "Qty", "Val"},
+			theirsCols: []int{0, 1, 3, 2},
+			mineCols:   []int{0, 1, 2, 3},
+			changes:    []columnChange{{name: "Val", changeType: columnMoved, theirsCol: 2, mineCol: 3}},
+		},
+		{
+			name:       "blank headers",
+			theirs:     []string{"ID", "", "Qty", ""},
+			mine:       []string{"ID", "", "", "Qty"},
+			theirsCols: []int{0, 1, 3, 2},
+			mineCols:   []int{0, 1, 2, 3},
+			changes:    []columnChange{{name: "Qty", changeType: columnMoved, theirsCol: 2, mineCol: 3}},
+		},
+		{
+			name:       "no matching headers",
+			theirs:     []string{"A", "B"},
+			mine:       []string{"C", "D", "E"},
+			theirsCols: []int{0, 1, 2},
+			mineCols:   []int{0, 1, 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, alignment := alignColumns([][]string{test.theirs}, [][]string{test.mine})
+
+			if !reflect.DeepEqual(alignment.theirsCols, test.theirsCols) {
+				t.Errorf("theirs columns %v, want %v", alignment.theirsCols, test.theirsCols)
+			}
+			if !reflect.DeepEqual(alignment.mineCols, test.mineCols) {
+				t.Errorf("mine columns %v, want %v", alignment.mineCols, test.mineCols)
+			}
+			if !reflect.DeepEqual(alignment.changes, test.changes) {
+				t.Errorf("changes %+v, want %+v", alignment.changes, test.changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type cellComment struct {
+	author string
+	text   string
+}
+
+// a comment that changed. Cell is the cell in mine, or in theirs when the
+// comment was removed
+type commentChange struct {
+	cell       string
+	theirsCell string
+	mineCell   string
+	changeType differenceType
+	theirs     cellComment
+	mine       cellComment
+}
+
+// reads the comments of the sheet keyed by cell reference. Returns nil if the
+// sheet does not exist in the workbook
+func readSheetComments(excelFile *excelize.File, sheet string) map[string]cellComment {
+	if excelFile == nil {
+		return nil
+	}
+
+	if index, err := excelFile.GetSheetIndex(sheet); err != nil || index < 0 {
+		return nil
+	}
+
+	comments, err := excelFile.GetComments(sheet)
+	if err != nil {
+		panic(err)
+	}
+
+	sheetComments := make(map[string]cellComment)
+
+	for _, comment := range comments {
+		text := comment.Text
+		for _, run := range comment.Paragraph {
+			text += run.Text
+		}
+
+		// excel starts the text of a note with the name of the author
+		text = strings.TrimPrefix(text, comment.Author+":")
+
+		sheetComments[comment.Cell] = cellComment{author: comment.Author, text: strings.TrimSpace(text)}
+	}
+
+	return sheetComments
+}
+
+// the cell of mine that each cell of theirs in the table was matched to. Rows
+// are matched the same way as the cell diff and columns by the column alignment
+func (diff sheetDiff) commentCellMap() map[string]string {
+	cellMap := make(map[string]string)
+
+	for _, line := range diff.mergedRows() {
+		if line.theirsRow == 0 || line.mineRow == 0 {
+			continue
+		}
+		for col := range diff.columns.mineCols {
+			cellMap[cellReference(diff.columns.theirsCols, col, line.theirsRow)] = cellReference(diff.columns.mineCols, col, line.mineRow)
+		}
+	}
+
+	return cellMap
+}
+
+// whether the zero based row and column are in the part of the sheet that was
+// diffed as a table with the given number of rows
+func (region sheetRegion) contains(row int, col int, rowCount int) bool {
+	if row < region.headerRow || row >= region.headerRow+rowCount {
+		return false
+	}
+	return col >= region.firstCol && (region.lastCol < 0 || col <= region.lastCol)
+}
+
+func cellInTable(cell string, region sheetRegion, rowCount int) bool {
+	col, row, err := excelize.CellNameToCoordinates(cell)
+	if err != nil {
+		return false
+	}
+	return region.contains(row-1, col-1, rowCount)
+}
+
+// lists the comments that were added, removed or edited ordered by cell. A
+// comment in the table is compared with the comment on the cell it was matched
+// to, so comments move with their rows and columns. Comments outside of the
+// table are compared by cell
+func (diff sheetDiff) compareComments(theirsComments, mineComments map[string]cellComment) []commentChange {
+	var changes []commentChange
+
+	cellMap := diff.commentCellMap()
+
+	// theirs comments keyed by the cell of mine they were matched to
+	theirsCells := make(map[string]string)
+	for cell := range theirsComments {
+		if mineCell, ok := cellMap[cell]; ok {
+			theirsCells[mineCell] = cell
+		} else if !cellInTable(cell, diff.theirsRegion, len(diff.dataTheirs)) && !cellInTable(cell, diff.mineRegion, len(diff.dataMine)) {
+			theirsCells[cell] = cell
+		}
+	}
+
+	matched := make(map[string]bool)
+	for cell, mineComment := range mineComments {
+		theirsCell, ok := theirsCells[cell]
+		if !ok {
+			changes = append(changes, commentChange{cell: cell, mineCell: cell, changeType: addition, mine: mineComment})
+			continue
+		}
+
+		matched[theirsCell] = true
+		if theirsComment := theirsComments[theirsCell]; theirsComment != mineComment {
+			changes = append(changes, commentChange{cell: cell, theirsCell: theirsCell, mineCell: cell, changeType: difference, theirs: theirsComment, mine: mineComment})
+		}
+	}
+
+	for cell, theirsComment := range theirsComments {
+		if !matched[cell] {
+			changes = append(changes, commentChange{cell: cell, theirsCell: cell, changeType: deletion, theirs: theirsComment})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return cellBefore(changes[i].cell, changes[j].cell)
+	})
+
+	return changes
+}
+
+// orders cell references by row and then by column
+func cellBefore(first, second string) bool {
+	firstCol, firstRow, firstErr := excelize.CellNameToCoordinates(first)
+	secondCol, secondRow, secondErr := excelize.CellNameToCoordinates(second)
+
+	if firstErr != nil || secondErr != nil {
+		return first < second
+	}
+
+	if firstRow != secondRow {
+		return firstRow < secondRow
+	}
+	return firstCol < secondCol
+}
+
+func commentChangeString(change commentChange) string {
+	switch change.changeType {
+	case addition:
+		return "Comment added"
+	case deletion:
+		return "Comment removed"
+	default:
+		return "Comment edited"
+	}
+}
+
+// the cell of the comment and the cell it was on in theirs if it moved
+func commentCellString(change commentChange) string {
+	if change.theirsCell != "" && change.mineCell != "" && change.theirsCell != change.mineCell {
+		return change.mineCell + " (was " + change.theirsCell + ")"
+	}
+	return change.cell
+}
+
+func commentString(comment cellComment) string {
+	if comment.author == "" {
+		return comment.text
+	}
+	return comment.author + ": " + comment.text
+}
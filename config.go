@@ -24,7 +24,7 @@ func readConfg() gedConfig {
 	var userConfig gedConfig
 	configBytes, err := os.ReadFile(configFileLocation)
 	if err != nil {
-		fmt.Printf("WARNING: Unable to read config file...\n")
+		fmt.Fprintf(os.Stderr, "WARNING: Unable to read config file...\n")
 		userConfig.DefaultCommit = "origin/main"
 		return userConfig
 	}
@@ -32,7 +32,7 @@ func readConfg() gedConfig {
 	err = json.NewDecoder(bytes.NewBuffer(configBytes)).Decode(&userConfig)
 
 	if err != nil {
-		fmt.Printf("WARNING: Unable to read json config...\n")
+		fmt.Fprintf(os.Stderr, "WARNING: Unable to read json config...\n")
 		userConfig.DefaultCommit = "origin/main"
 		return userConfig
 	}
@@ -54,7 +54,7 @@ func updateConfigFile(newConfig gedConfig) {
 func checkConfigFileExists() {
 	configDir, err := stringGetConfigDir()
 	if err != nil {
-		fmt.Printf("WARNING: Error getting config dir...\n")
+		fmt.Fprintf(os.Stderr, "WARNING: Error getting config dir...\n")
 		return
 	}
 
@@ -67,7 +67,7 @@ func checkConfigFileExists() {
 		os.Mkdir(configDir, 0700)
 		configFile, err := os.Create(configFileLocation)
 		if err != nil {
-			fmt.Printf("WARNING: Unable to create config file. Aborting...\n")
+			fmt.Fprintf(os.Stderr, "WARNING: Unable to create config file. Aborting...\n")
 			return
 		}
 
@@ -80,15 +80,15 @@ func checkConfigFileExists() {
 		jsonData, err := json.MarshalIndent(defaultConfig, "", "    ")
 
 		if err != nil {
-			fmt.Printf("WARNING: Unable to create json data...\n")
+			fmt.Fprintf(os.Stderr, "WARNING: Unable to create json data...\n")
 			return
 		}
 
-		fmt.Printf("Creating new config file: %s\n", configFileLocation)
-		fmt.Printf("Default Config: %s \n", jsonData)
+		fmt.Fprintf(os.Stderr, "Creating new config file: %s\n", configFileLocation)
+		fmt.Fprintf(os.Stderr, "Default Config: %s \n", jsonData)
 		_, err = configFile.Write(jsonData)
 		if err != nil {
-			fmt.Printf("WARNING: Unable to write to config file...\n")
+			fmt.Fprintf(os.Stderr, "WARNING: Unable to write to config file...\n")
 		}
 
 	}
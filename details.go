@@ -0,0 +1,172 @@
+package main
+
+import (
+	"github.com/xuri/excelize/v2"
+)
+
+// cell information that is compared along with the cell values. Each grid is
+// indexed by sheet row and column until it is aligned with the sheet data
+type cellDetails struct {
+	formulas [][]string
+	styles   [][]string
+
+	// comments and rules belong to cells and ranges of the sheet and are
+	// compared by reference so they are never aligned
+	comments map[string]cellComment
+	rules    []sheetRule
+}
+
+// reads a value for every cell in the sheet that GetRows returns. Returns nil
+// if the sheet does not exist in the workbook
+func readCellGrid(excelFile *excelize.File, sheet string, readCell func(cellName string) string) [][]string {
+	if excelFile == nil {
+		return nil
+	}
+
+	rows, err := excelFile.GetRows(sheet)
+	if err != nil {
+		return nil
+	}
+
+	maxRowLen := 0
+	for _, row := range rows {
+		maxRowLen = max(maxRowLen, len(row))
+	}
+
+	var grid [][]string
+
+	for rowIndex := range rows {
+		var gridRow []string
+		for colIndex := 0; colIndex < maxRowLen; colIndex++ {
+			cellName, err := excelize.CoordinatesToCellName(colIndex+1, rowIndex+1)
+			if err != nil {
+				panic(err)
+			}
+			gridRow = append(gridRow, readCell(cellName))
+		}
+		grid = append(grid, gridRow)
+	}
+
+	return grid
+}
+
+// reads the formula of every cell in the sheet
+func readSheetFormulas(excelFile *excelize.File, sheet string) [][]string {
+	return readCellGrid(excelFile, sheet, func(cellName string) string {
+		formula, err := excelFile.GetCellFormula(sheet, cellName)
+		if err != nil {
+			panic(err)
+		}
+
+		if formula != "" {
+			formula = "=" + formula
+		}
+		return formula
+	})
+}
+
+// lines a detail grid up with the region data and its aligned columns
+func alignDetailGrid(grid [][]string, region sheetRegion, rowCount int, cols []int) [][]string {
+	if grid == nil {
+		return nil
+	}
+
+	var aligned [][]string
+
+	for index := 0; index < rowCount; index++ {
+		var sheetRow []string
+		if region.headerRow+index < len(grid) {
+			sheetRow = grid[region.headerRow+index]
+		}
+
+		var alignedRow []string
+		for _, col := range cols {
+			alignedRow = append(alignedRow, cellAt(sheetRow, col))
+		}
+		aligned = append(aligned, alignedRow)
+	}
+
+	return aligned
+}
+
+func alignCellDetails(details cellDetails, region sheetRegion, rowCount int, cols []int) cellDetails {
+	return cellDetails{
+		formulas: alignDetailGrid(details.formulas, region, rowCount, cols),
+		styles:   alignDetailGrid(details.styles, region, rowCount, cols),
+		comments: details.comments,
+		rules:    details.rules,
+	}
+}
+
+func detailAt(grid [][]string, row int, col int) string {
+	if row < 0 || row >= len(grid) {
+		return ""
+	}
+	return cellAt(grid[row], col)
+}
+
+// one string per row holding all of the details of the row so rows can be
+// compared with their details
+func rowDetailStrings(details cellDetails, rowCount int) []string {
+	var rowDetails []string
+
+	for index := 0; index < rowCount; index++ {
+		var rowString string
+		if index < len(details.formulas) {
+			rowString += rowToString(details.formulas[index])
+		}
+		if index < len(details.styles) {
+			rowString += "%@!#!@%" + rowToString(details.styles[index])
+		}
+		rowDetails = append(rowDetails, rowString)
+	}
+
+	return rowDetails
+}
+
+func createDetailMap(data [][]string, rowDetails []string, keyIndexes []int) map[string]string {
+	detailMap := make(map[string]string)
+
+	for index, row := range data {
+		detailMap[concatKeysData(row, keyIndexes)] = cellAt(rowDetails, index)
+	}
+
+	return detailMap
+}
+
+// true if the value or formula of the cell changed
+func cellContentDiffers(dataTheirs, dataMine [][]string, detailsTheirs, detailsMine cellDetails, theirsPos, minePos, col int) bool {
+	if cellAt(dataTheirs[theirsPos], col) != cellAt(dataMine[minePos], col) {
+		return true
+	}
+
+	return detailAt(detailsTheirs.formulas, theirsPos, col) != detailAt(detailsMine.formulas, minePos, col)
+}
+
+func cellStyleDiffers(detailsTheirs, detailsMine cellDetails, theirsPos, minePos, col int) bool {
+	return detailAt(detailsTheirs.styles, theirsPos, col) != detailAt(detailsMine.styles, minePos, col)
+}
+
+// marks changed lines where only the cell styles changed as style changes
+func classifyStyleChanges(lines []differenceLine, dataTheirs, dataMine [][]string, detailsTheirs, detailsMine cellDetails) []differenceLine {
+	for index, line := range lines {
+		if line.lineType != difference {
+			continue
+		}
+
+		contentChanged := false
+		width := max(len(dataTheirs[line.theirsPos]), len(dataMine[line.minePos]))
+		for col := 0; col < width; col++ {
+			if cellContentDiffers(dataTheirs, dataMine, detailsTheirs, detailsMine, line.theirsPos, line.minePos, col) {
+				contentChanged = true
+				break
+			}
+		}
+
+		if !contentChanged {
+			lines[index].lineType = styleChange
+		}
+	}
+
+	return lines
+}
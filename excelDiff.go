@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/mxschmitt/golang-combinations"
@@ -17,14 +18,75 @@ import (
 type differenceType int
 
 const (
-	difference differenceType = iota
-	addition                  = iota
+	difference  differenceType = iota
+	addition                   = iota
+	deletion                   = iota
+	styleChange                = iota
+	unchanged                  = iota
 )
 
 type differenceLine struct {
-	key      string
-	lineType differenceType
-	linePos  int
+	key       string
+	lineType  differenceType
+	linePos   int
+	theirsPos int
+	minePos   int
+	theirsRow int // row number in the theirs sheet, 0 if the line is not in theirs
+	mineRow   int // row number in the mine sheet, 0 if the line is not in mine
+}
+
+// the number of changes found in a sheet or workbook. Other counts changes
+// that are not rows such as columns, comments, rules, sheets and names
+type diffCounts struct {
+	added   int
+	removed int
+	changed int
+	other   int
+}
+
+func (counts *diffCounts) addLines(lines []differenceLine) {
+	for _, line := range lines {
+		switch line.lineType {
+		case addition:
+			counts.added++
+		case deletion:
+			counts.removed++
+		default:
+			counts.changed++
+		}
+	}
+}
+
+func (counts *diffCounts) add(other diffCounts) {
+	counts.added += other.added
+	counts.removed += other.removed
+	counts.changed += other.changed
+	counts.other += other.other
+}
+
+// reads the rows of a sheet padded so every row has the same number of cells
+func readPaddedRows(excelFile *excelize.File, sheet string) [][]string {
+	rows, err := excelFile.GetRows(sheet)
+	if err != nil {
+		panic(err)
+	}
+
+	maxRowLen := 0
+	for _, row := range rows {
+
+		if len(row) > maxRowLen {
+			maxRowLen = len(row)
+		}
+	}
+
+	for index, row := range rows {
+		for len(row) < maxRowLen {
+			row = append(row, "")
+		}
+		rows[index] = row
+	}
+
+	return rows
 }
 
 // write Sheets to csv
@@ -34,18 +96,7 @@ func writeSheetsToCsv(excelFile *excelize.File, mine bool) []string {
 	var sheetNames []string
 
 	for _, sheet := range excelSheetNames {
-		rows, err := excelFile.GetRows(sheet)
-		if err != nil {
-			panic(err)
-		}
-
-		maxRowLen := 0
-		for _, row := range rows {
-
-			if len(row) > maxRowLen {
-				maxRowLen = len(row)
-			}
-		}
+		rows := readPaddedRows(excelFile, sheet)
 
 		sheetNames = append(sheetNames, sheet)
 
@@ -59,11 +110,6 @@ func writeSheetsToCsv(excelFile *excelize.File, mine bool) []string {
 		csvwriter := csv.NewWriter(csvFile)
 
 		for _, row := range rows {
-			if len(row) < maxRowLen {
-				for len(row) < maxRowLen {
-					row = append(row, "")
-				}
-			}
 			csvwriter.Write(row)
 			csvwriter.Flush()
 		}
@@ -84,6 +130,13 @@ func containsString(s []string, e string) bool {
 	return false
 }
 
+func cellAt(row []string, index int) string {
+	if index < len(row) {
+		return row[index]
+	}
+	return ""
+}
+
 func removeFiles(sheets []string, mine bool) {
 	for _, sheet := range sheets {
 		err := os.Remove(getSheetFileName(sheet, mine))
@@ -93,20 +146,24 @@ func removeFiles(sheets []string, mine bool) {
 	}
 }
 
-func concatSheetNames(theirsSheets, mineSheets []string) []string {
-	var sheets []string
+// reads the csv written for the sheet. A blank sheet name gives an empty sheet
+func readSheetCsv(sheet string, mine bool) [][]string {
+	if sheet == "" {
+		return [][]string{}
+	}
 
-	for _, sheet := range theirsSheets {
-		sheets = append(sheets, sheet)
+	csvFile, err := os.Open(getSheetFileName(sheet, mine))
+	if err != nil {
+		panic(err)
 	}
+	defer csvFile.Close()
 
-	for _, sheet := range mineSheets {
-		if !containsString(sheets, sheet) {
-			sheets = append(sheets, sheet)
-		}
+	data, err := csv.NewReader(csvFile).ReadAll()
+	if err != nil {
+		panic(err)
 	}
 
-	return sheets
+	return data
 }
 
 func getSheetFileName(sheet string, mine bool) string {
@@ -205,6 +262,12 @@ func primaryKeysUnique(data [][]string, keyIndexes []int) error {
 	return nil
 }
 
+// the key in the error from primaryKeysUnique is joined with the separator
+func readableKeyError(err error) string {
+	message := strings.Replace(err.Error(), "%@!#!@%", "", 1)
+	return strings.ReplaceAll(message, "%@!#!@%", ", ")
+}
+
 func createDataMap(data [][]string, keyIndexes []int) map[string][]string {
 	index := 0
 	maxLen := len(data)
@@ -277,200 +340,526 @@ func findMissing(baseList []string, compareList []string) []string {
 	return missingKeys
 }
 
-func rowsToStrings(data [][]string) []string {
-	var rowStrings []string
+func findDifferences(theirsDataMap, mineDataMap map[string][]string, theirsDetailMap, mineDetailMap map[string]string) []string {
+	var differentRows []string
 
-	for _, row := range data {
-		rowStrings = append(rowStrings, strings.Join(row, " "))
+	for key := range theirsDataMap {
+		// rows missing from mine are deletions not differences
+		if _, ok := mineDataMap[key]; !ok {
+			continue
+		}
+		if strings.Join(theirsDataMap[key], " ") != strings.Join(mineDataMap[key], " ") || theirsDetailMap[key] != mineDetailMap[key] {
+			differentRows = append(differentRows, key)
+		}
 	}
 
-	return rowStrings
+	return differentRows
 }
 
-func findDiffRows(dataTheirs [][]string, dataMine [][]string) ([][]string, [][]string) {
-	var mineDiff [][]string
-	var theirsDiff [][]string
+// orders the diff lines by where they fall in mine. Deleted lines are placed
+// directly after the closest row before them in theirs that still exists in mine
+func orderAndTypeDiffLines(missingFromTheirs []string, missingFromMine []string, differentKeys []string, dataTheirs [][]string, dataMine [][]string, primaryKeyIndexes []int) []differenceLine {
+	var lineDiffs []differenceLine
+
+	minePositions := make(map[string]int)
+	for index, line := range dataMine {
+		minePositions[concatKeysData(line, primaryKeyIndexes)] = index
+	}
+
+	theirsPositions := make(map[string]int)
+	for index, line := range dataTheirs {
+		theirsPositions[concatKeysData(line, primaryKeyIndexes)] = index
+	}
+
+	for _, key := range missingFromTheirs {
+		lineDiffs = append(lineDiffs, differenceLine{key: key, lineType: addition, linePos: minePositions[key], theirsPos: -1, minePos: minePositions[key]})
+	}
 
-	theirRowStrings := rowsToStrings(dataTheirs)
-	mineRowStrings := rowsToStrings(dataMine)
+	for _, key := range differentKeys {
+		lineDiffs = append(lineDiffs, differenceLine{key: key, lineType: difference, linePos: minePositions[key], theirsPos: theirsPositions[key], minePos: minePositions[key]})
+	}
 
-	for _, row := range dataTheirs {
-		if !containsString(mineRowStrings, strings.Join(row, " ")) {
-			theirsDiff = append(theirsDiff, row)
+	for _, key := range missingFromMine {
+		theirsPos := theirsPositions[key]
+		anchor := -1
+		for index := theirsPos - 1; index >= 0; index-- {
+			if minePos, ok := minePositions[concatKeysData(dataTheirs[index], primaryKeyIndexes)]; ok {
+				anchor = minePos
+				break
+			}
 		}
+		lineDiffs = append(lineDiffs, differenceLine{key: key, lineType: deletion, linePos: anchor, theirsPos: theirsPos, minePos: -1})
 	}
 
-	for _, row := range dataMine {
-		if !containsString(theirRowStrings, strings.Join(row, " ")) {
-			mineDiff = append(mineDiff, row)
+	sort.SliceStable(lineDiffs, func(i, j int) bool {
+		if lineDiffs[i].linePos != lineDiffs[j].linePos {
+			return lineDiffs[i].linePos < lineDiffs[j].linePos
+		}
+		// the anchor row comes before the rows deleted after it
+		if (lineDiffs[i].lineType == deletion) != (lineDiffs[j].lineType == deletion) {
+			return lineDiffs[j].lineType == deletion
+		}
+		return lineDiffs[i].theirsPos < lineDiffs[j].theirsPos
+	})
+
+	return lineDiffs
+}
+
+// converts the line positions in the region data to row numbers in the sheets
+func setSheetRows(lines []differenceLine, theirsRegion, mineRegion sheetRegion) []differenceLine {
+	for index := range lines {
+		if lines[index].theirsPos >= 0 {
+			lines[index].theirsRow = lines[index].theirsPos + theirsRegion.headerRow + 1
+		}
+		if lines[index].minePos >= 0 {
+			lines[index].mineRow = lines[index].minePos + mineRegion.headerRow + 1
 		}
 	}
 
-	return theirsDiff, mineDiff
+	return lines
 }
 
-func findDifferences(theirsDataMap, mineDataMap map[string][]string) []string {
-	var differentRows []string
+func setHunkSheetRows(hunk diffHunk, theirsRegion, mineRegion sheetRegion) diffHunk {
+	hunk.theirsStart += theirsRegion.headerRow
+	hunk.mineStart += mineRegion.headerRow
+	hunk.lines = setSheetRows(hunk.lines, theirsRegion, mineRegion)
 
-	for key := range theirsDataMap {
-		if strings.Join(theirsDataMap[key], " ") != strings.Join(mineDataMap[key], " ") {
-			differentRows = append(differentRows, key)
+	return hunk
+}
+
+// the differences found in one sheet. Lines holds the row changes when the
+// rows were matched by primary key and hunks holds them when they were not
+type sheetDiff struct {
+	name           string
+	theirsSheet    string
+	mineSheet      string
+	theirsRegion   sheetRegion
+	mineRegion     sheetRegion
+	columns        columnAlignment
+	commentChanges []commentChange
+	ruleChanges    []ruleChange
+	equalMessage   string
+	primaryKeys    []string
+	smartCompare   bool
+	fallbackReason string
+	header         []string
+	dataTheirs     [][]string
+	dataMine       [][]string
+	detailsTheirs  cellDetails
+	detailsMine    cellDetails
+	lines          []differenceLine
+	hunks          []diffHunk
+	counts         diffCounts
+}
+
+// a cell of a changed row. The cells are A1 references, blank when the row is
+// not on that side. Styles only hold the parts of the style that changed
+type cellChange struct {
+	column        string
+	theirsCell    string
+	mineCell      string
+	theirs        string
+	mine          string
+	theirsFormula string
+	mineFormula   string
+	theirsStyle   string
+	mineStyle     string
+}
+
+// a column of the primary key and its value in a row
+type keyValue struct {
+	column string
+	value  string
+}
+
+// the A1 reference of a column of the aligned data in the sheet
+func cellReference(cols []int, col int, row int) string {
+	if row == 0 || col >= len(cols) {
+		return ""
+	}
+	return columnLetter(cols[col]) + strconv.Itoa(row)
+}
+
+// the cells of the line that changed. Every non empty cell is listed for rows
+// that were added or removed
+func (diff sheetDiff) cellChanges(line differenceLine) []cellChange {
+	var changes []cellChange
+
+	var theirsRow, mineRow []string
+	if line.theirsPos >= 0 && line.theirsPos < len(diff.dataTheirs) {
+		theirsRow = diff.dataTheirs[line.theirsPos]
+	}
+	if line.minePos >= 0 && line.minePos < len(diff.dataMine) {
+		mineRow = diff.dataMine[line.minePos]
+	}
+
+	for col := 0; col < max(len(theirsRow), len(mineRow)); col++ {
+		change := cellChange{column: cellAt(diff.header, col)}
+
+		if theirsRow != nil {
+			change.theirsCell = cellReference(diff.columns.theirsCols, col, line.theirsRow)
+			change.theirs = cellAt(theirsRow, col)
+			change.theirsFormula = detailAt(diff.detailsTheirs.formulas, line.theirsPos, col)
 		}
+		if mineRow != nil {
+			change.mineCell = cellReference(diff.columns.mineCols, col, line.mineRow)
+			change.mine = cellAt(mineRow, col)
+			change.mineFormula = detailAt(diff.detailsMine.formulas, line.minePos, col)
+		}
+
+		switch line.lineType {
+		case addition:
+			if change.mine == "" && change.mineFormula == "" {
+				continue
+			}
+		case deletion:
+			if change.theirs == "" && change.theirsFormula == "" {
+				continue
+			}
+		default:
+			contentDiffers := cellContentDiffers(diff.dataTheirs, diff.dataMine, diff.detailsTheirs, diff.detailsMine, line.theirsPos, line.minePos, col)
+			styleDiffers := cellStyleDiffers(diff.detailsTheirs, diff.detailsMine, line.theirsPos, line.minePos, col)
+			if !contentDiffers && !styleDiffers {
+				continue
+			}
+			if styleDiffers {
+				change.theirsStyle, change.mineStyle = styleDifference(detailAt(diff.detailsTheirs.styles, line.theirsPos, col), detailAt(diff.detailsMine.styles, line.minePos, col))
+			}
+		}
+
+		changes = append(changes, change)
 	}
 
-	return differentRows
+	return changes
 }
 
-// TODO: add more intelligence for additional lines to be in correct order
-func orderAndTypeDiffLines(missingFromTheirs []string, differentKeys []string, dataTheirs [][]string, dataMine [][]string, primaryKeyIndexes []int) []differenceLine {
-	var lineDiffs []differenceLine
+// the primary key of the line, nil when rows were not matched by primary key
+func (diff sheetDiff) lineKey(line differenceLine) []keyValue {
+	if !diff.smartCompare || len(diff.primaryKeys) == 0 {
+		return nil
+	}
 
-	for _, key := range missingFromTheirs {
-		for index, line := range dataMine {
-			if key == concatKeysData(line, primaryKeyIndexes) {
-				lineDiffs = append(lineDiffs, differenceLine{key: key, lineType: addition, linePos: index})
-				break
+	row := []string{}
+	if line.minePos >= 0 && line.minePos < len(diff.dataMine) {
+		row = diff.dataMine[line.minePos]
+	} else if line.theirsPos >= 0 && line.theirsPos < len(diff.dataTheirs) {
+		row = diff.dataTheirs[line.theirsPos]
+	}
+
+	var key []keyValue
+	for _, index := range findPrimaryKeyIndexes(diff.header, diff.primaryKeys) {
+		key = append(key, keyValue{column: diff.header[index], value: cellAt(row, index)})
+	}
+
+	return key
+}
+
+// the row changes of the sheet in order, from the lines or the hunks
+func (diff sheetDiff) allLines() []differenceLine {
+	lines := diff.lines
+	for _, hunk := range diff.hunks {
+		lines = append(lines, hunk.lines...)
+	}
+	return lines
+}
+
+// every row of mine in order with the removed rows placed where they used to
+// be. Rows that did not change are given the unchanged type
+func (diff sheetDiff) mergedRows() []differenceLine {
+	changedRows := make(map[int]differenceLine)
+	// removed rows keyed by the position of the mine row they come after, -1 when
+	// they come before the first row
+	removedAfter := make(map[int][]differenceLine)
+	theirsUsed := make(map[int]bool)
+
+	addLine := func(line differenceLine, anchor int) {
+		if line.theirsPos >= 0 {
+			theirsUsed[line.theirsPos] = true
+		}
+		if line.minePos >= 0 {
+			changedRows[line.minePos] = line
+		} else {
+			removedAfter[anchor] = append(removedAfter[anchor], line)
+		}
+	}
+
+	// smart compare stores the anchor of removed rows as their line position
+	for _, line := range diff.lines {
+		addLine(line, line.linePos)
+	}
+
+	for _, hunk := range diff.hunks {
+		anchor := hunk.mineStart - diff.mineRegion.headerRow - 1
+		for _, line := range hunk.lines {
+			addLine(line, anchor)
+			if line.minePos >= 0 {
+				anchor = line.minePos
 			}
 		}
 	}
 
-	for _, key := range differentKeys {
-		for index, line := range dataTheirs {
-			if key == concatKeysData(line, primaryKeyIndexes) {
-				lineDiffs = append(lineDiffs, differenceLine{key: key, lineType: difference, linePos: index})
-				break
+	// unchanged rows are matched to theirs by primary key, or in order when the
+	// sequence diff was used
+	var theirsKeyIndexes []int
+	var mineKeyIndexes []int
+	theirsPositions := make(map[string]int)
+	if diff.smartCompare && len(diff.dataTheirs) > 0 && len(diff.dataMine) > 0 {
+		theirsKeyIndexes = findPrimaryKeyIndexes(diff.dataTheirs[0], diff.primaryKeys)
+		mineKeyIndexes = findPrimaryKeyIndexes(diff.dataMine[0], diff.primaryKeys)
+		for index, row := range diff.dataTheirs {
+			theirsPositions[rowKey(row, theirsKeyIndexes)] = index
+		}
+	}
+
+	rows := removedAfter[-1]
+	nextTheirs := 0
+	for minePos := range diff.dataMine {
+		line, changed := changedRows[minePos]
+		if !changed {
+			line = differenceLine{lineType: unchanged, theirsPos: -1, minePos: minePos}
+			if len(mineKeyIndexes) > 0 {
+				if theirsPos, ok := theirsPositions[rowKey(diff.dataMine[minePos], mineKeyIndexes)]; ok {
+					line.theirsPos = theirsPos
+				}
+			} else {
+				for theirsUsed[nextTheirs] {
+					nextTheirs++
+				}
+				if nextTheirs < len(diff.dataTheirs) {
+					line.theirsPos = nextTheirs
+					nextTheirs++
+				}
 			}
+			line = setSheetRows([]differenceLine{line}, diff.theirsRegion, diff.mineRegion)[0]
 		}
+
+		rows = append(rows, line)
+		rows = append(rows, removedAfter[minePos]...)
 	}
 
-	sort.SliceStable(lineDiffs, func(i, j int) bool {
-		return lineDiffs[i].linePos < lineDiffs[j].linePos
-	})
+	return rows
+}
 
-	return lineDiffs
+// the primary key of a row that may be shorter than the header
+func rowKey(row []string, keyIndexes []int) string {
+	var key string
+	for _, index := range keyIndexes {
+		key += "%@!#!@%" + cellAt(row, index)
+	}
+	return key
 }
 
-func compareCSV(dataTheirs [][]string, dataMine [][]string, primaryKeys []string, sheetName string, htmlFile *os.File, smartCompare bool) {
+// describes the column, comment and rule changes of the sheet
+func (diff sheetDiff) otherChanges() []string {
+	var changes []string
+
+	for _, change := range diff.columns.changes {
+		value := columnChangeString(change) + ": " + change.name
+		if change.changeType == columnRenamed {
+			value += " (was " + change.oldName + ")"
+		}
+		changes = append(changes, value)
+	}
+
+	for _, change := range diff.commentChanges {
+		changes = append(changes, commentChangeString(change)+" "+commentCellString(change)+": "+textChangedValue(commentString(change.theirs), commentString(change.mine), change.changeType))
+	}
+
+	for _, change := range diff.ruleChanges {
+		changes = append(changes, ruleChangeString(change)+": "+textChangedValue(change.theirsRanges, change.mineRanges, change.changeType))
+	}
+
+	return changes
+}
+
+// the primary key the rows were matched by or row order for the sequence diff
+func (diff sheetDiff) comparedBy() string {
+	if diff.smartCompare && len(diff.primaryKeys) > 0 {
+		return strings.Join(diff.primaryKeys, ", ")
+	}
+	return "row order"
+}
+
+func rowChangeLabel(lineType differenceType) string {
+	switch lineType {
+	case addition:
+		return "Row added"
+	case deletion:
+		return "Row removed"
+	case styleChange:
+		return "Style changed"
+	default:
+		return "Row changed"
+	}
+}
+
+func changeTypeString(lineType differenceType) string {
+	switch lineType {
+	case addition:
+		return "added"
+	case deletion:
+		return "removed"
+	case styleChange:
+		return "style"
+	default:
+		return "changed"
+	}
+}
+
+// turns smart compare off and records why
+func (diff *sheetDiff) fallBack(reason string) {
+	fmt.Printf("%s. Using default diff algorithm for %s\r\n", reason, diff.name)
+	diff.smartCompare = false
+	diff.fallbackReason = reason
+	diff.primaryKeys = nil
+}
+
+func compareCSV(dataTheirs [][]string, dataMine [][]string, detailsTheirs cellDetails, detailsMine cellDetails, primaryKeys []string, sheetName string, region *sheetRegion, smartCompare bool) sheetDiff {
+	diff := sheetDiff{name: sheetName, smartCompare: smartCompare}
+
+	diff.theirsRegion = resolveRegion(dataTheirs, region)
+	diff.mineRegion = resolveRegion(dataMine, region)
+
+	dataTheirs = extractRegion(dataTheirs, diff.theirsRegion)
+	dataMine = extractRegion(dataMine, diff.mineRegion)
+
+	dataTheirs, dataMine, columns := alignColumns(dataTheirs, dataMine)
+	diff.columns = offsetColumns(columns, diff.theirsRegion.firstCol, diff.mineRegion.firstCol)
+
+	detailsTheirs = alignCellDetails(detailsTheirs, diff.theirsRegion, len(dataTheirs), diff.columns.theirsCols)
+	detailsMine = alignCellDetails(detailsMine, diff.mineRegion, len(dataMine), diff.columns.mineCols)
+	theirsRowDetails := rowDetailStrings(detailsTheirs, len(dataTheirs))
+	mineRowDetails := rowDetailStrings(detailsMine, len(dataMine))
+
+	diff.dataTheirs = dataTheirs
+	diff.dataMine = dataMine
+	diff.detailsTheirs = detailsTheirs
+	diff.detailsMine = detailsMine
+
+	if len(dataMine) > 0 {
+		diff.header = dataMine[0]
+	} else if len(dataTheirs) > 0 {
+		diff.header = dataTheirs[0]
+	}
 
 	if !smartCompare {
 		fmt.Printf("Smart compare turned off using default diff algorithm for %s\r\n", sheetName)
+		diff.fallbackReason = "Smart compare turned off"
 	}
 
-	if len(primaryKeys) == 0 && smartCompare {
+	if len(primaryKeys) == 0 && diff.smartCompare {
 		fmt.Printf("Attempting to find primary key for %s\r\n", sheetName)
 		primaryKeys = autoFindPrimaryKeyNames(dataMine, dataTheirs)
 		if len(primaryKeys) == 0 {
-			fmt.Printf("Unable to find suitable primary key. Using default diff algorithm for %s\r\n", sheetName)
-			smartCompare = false
+			diff.fallBack("Unable to find suitable primary key")
 		} else {
 			fmt.Printf("Primary key %s found for %s\r\n", primaryKeys, sheetName)
 		}
 	}
+	if diff.smartCompare {
+		diff.primaryKeys = primaryKeys
+	}
 
 	var theirsPrimaryKeyIndexes []int
-	if len(dataTheirs) > 0 && smartCompare {
+	if len(dataTheirs) > 0 && diff.smartCompare {
 		theirsPrimaryKeyIndexes = findPrimaryKeyIndexes(dataTheirs[0], primaryKeys)
 	}
 
 	var minePrimaryKeyIndexes []int
-	if len(dataMine) > 0 && smartCompare {
+	if len(dataMine) > 0 && diff.smartCompare {
 		minePrimaryKeyIndexes = findPrimaryKeyIndexes(dataMine[0], primaryKeys)
 	}
 
 	if !reflect.DeepEqual(minePrimaryKeyIndexes, theirsPrimaryKeyIndexes) {
-		fmt.Printf("Primary key indexes don't match. Using default diff algorithm for %s\r\n", sheetName)
-		smartCompare = false
+		diff.fallBack("Primary key indexes don't match")
 	}
 
-	if (len(theirsPrimaryKeyIndexes) == 0 || len(minePrimaryKeyIndexes) == 0) && smartCompare {
-		fmt.Printf("Unable to find primary key. Using default diff algorithm for %s\r\n", sheetName)
-		smartCompare = false
+	if (len(theirsPrimaryKeyIndexes) == 0 || len(minePrimaryKeyIndexes) == 0) && diff.smartCompare {
+		diff.fallBack("Unable to find primary key")
 	}
 
-	dataTheirs, dataMine = normalizeData(dataTheirs, dataMine)
-
 	// check if keys are primary only if the previous checks passed
-	if smartCompare {
-		err := primaryKeysUnique(dataTheirs, theirsPrimaryKeyIndexes)
-		if err != nil {
-			fmt.Printf("%s theirs: %s\n", sheetName, err)
-			smartCompare = false
+	if diff.smartCompare {
+		if err := primaryKeysUnique(dataTheirs, theirsPrimaryKeyIndexes); err != nil {
+			diff.fallBack("Primary key is not unique in theirs: " + readableKeyError(err))
 		}
-
-		err = primaryKeysUnique(dataMine, minePrimaryKeyIndexes)
-
-		if err != nil {
-			fmt.Printf("%s mine: %s\n", sheetName, err)
-			smartCompare = false
+	}
+	if diff.smartCompare {
+		if err := primaryKeysUnique(dataMine, minePrimaryKeyIndexes); err != nil {
+			diff.fallBack("Primary key is not unique in mine: " + readableKeyError(err))
 		}
 	}
 
-	if smartCompare {
+	if diff.smartCompare {
 		theirsDataMap := createDataMap(dataTheirs, theirsPrimaryKeyIndexes)
 		mineDataMap := createDataMap(dataMine, minePrimaryKeyIndexes)
+		theirsDetailMap := createDetailMap(dataTheirs, theirsRowDetails, theirsPrimaryKeyIndexes)
+		mineDetailMap := createDetailMap(dataMine, mineRowDetails, minePrimaryKeyIndexes)
 
-		if reflect.DeepEqual(theirsDataMap, mineDataMap) {
-			htmlFile.Write([]byte(htmlAddSheetHeader(sheetName)))
-			htmlFile.Write([]byte(htmlAddSubHeading("Sheets are equal")))
-			htmlFile.Write([]byte(htmlAddBreakLine()))
-			return
+		if reflect.DeepEqual(theirsDataMap, mineDataMap) && reflect.DeepEqual(theirsDetailMap, mineDetailMap) {
+			diff.compareSheetDetails(detailsTheirs, detailsMine)
+			return diff
 		}
 
 		mineKeylist := listKeys(mineDataMap)
 		theirsKeylist := listKeys(theirsDataMap)
 
 		missingFromTheirs := findMissing(mineKeylist, theirsKeylist)
+		missingFromMine := findMissing(theirsKeylist, mineKeylist)
 
-		differentKeys := findDifferences(theirsDataMap, mineDataMap)
-
-		lineDifferences := orderAndTypeDiffLines(missingFromTheirs, differentKeys, dataTheirs, dataMine, minePrimaryKeyIndexes)
-
-		htmlFile.Write([]byte(htmlAddSheetHeader(sheetName)))
-		htmlFile.Write([]byte(htmlStartTable()))
-
-		htmlFile.Write([]byte(htmlAddTableHeaderDefaultDiff(dataMine[0])))
-
-		for _, diffLine := range lineDifferences {
-			htmlFile.Write([]byte(htmlAddDiffRow(theirsDataMap, mineDataMap, diffLine)))
-		}
+		differentKeys := findDifferences(theirsDataMap, mineDataMap, theirsDetailMap, mineDetailMap)
 
-		htmlFile.Write([]byte(htmlEndTable()))
-		htmlFile.Write([]byte(htmlAddBreakLine()))
+		lineDifferences := orderAndTypeDiffLines(missingFromTheirs, missingFromMine, differentKeys, dataTheirs, dataMine, minePrimaryKeyIndexes)
+		lineDifferences = classifyStyleChanges(lineDifferences, dataTheirs, dataMine, detailsTheirs, detailsMine)
+		diff.lines = setSheetRows(lineDifferences, diff.theirsRegion, diff.mineRegion)
+		diff.counts.addLines(diff.lines)
 	} else {
-		theirDiff, mineDiff := findDiffRows(dataTheirs, dataMine)
-
-		if theirDiff == nil && mineDiff == nil {
-			htmlFile.Write([]byte(htmlAddSheetHeader(sheetName)))
-			htmlFile.Write([]byte(htmlAddSubHeading("Sheets are equal")))
-			htmlFile.Write([]byte(htmlAddBreakLine()))
-			return
+		for _, hunk := range sequenceDiffHunks(dataTheirs, dataMine, theirsRowDetails, mineRowDetails) {
+			hunk.lines = classifyStyleChanges(hunk.lines, dataTheirs, dataMine, detailsTheirs, detailsMine)
+			hunk = setHunkSheetRows(hunk, diff.theirsRegion, diff.mineRegion)
+			diff.counts.addLines(hunk.lines)
+			diff.hunks = append(diff.hunks, hunk)
 		}
+	}
 
-		htmlFile.Write([]byte(htmlAddSheetHeader(sheetName)))
-		htmlFile.Write([]byte(htmlAddSubHeading("Mine")))
-		if len(mineDiff) > 0 {
-			htmlFile.Write([]byte(htmlStartTable()))
-			htmlFile.Write([]byte(htmlAddTableHeaderDefaultDiff(dataMine[0])))
+	diff.compareSheetDetails(detailsTheirs, detailsMine)
 
-			for _, diffLine := range mineDiff {
-				htmlFile.Write([]byte(htmlAddRow(diffLine)))
-			}
+	return diff
+}
 
-			htmlFile.Write([]byte(htmlEndTable()))
+// an added or removed sheet is diffed against no rows, so its header row is
+// listed with the data but not counted as an added or removed row
+func (diff *sheetDiff) uncountHeaderRow() {
+	for _, line := range diff.allLines() {
+		if !diff.sheetHeaderLine(line) {
+			continue
+		}
+		if line.lineType == addition {
+			diff.counts.added--
+		} else {
+			diff.counts.removed--
 		}
+		return
+	}
+}
 
-		htmlFile.Write([]byte(htmlAddSubHeading("Theirs")))
-		if len(theirDiff) > 0 {
-			htmlFile.Write([]byte(htmlStartTable()))
-			htmlFile.Write([]byte(htmlAddTableHeaderDefaultDiff(dataTheirs[0])))
+// checks if the line is the header row of an added or removed sheet
+func (diff sheetDiff) sheetHeaderLine(line differenceLine) bool {
+	return (diff.theirsSheet == "" && line.lineType == addition && line.minePos == 0) ||
+		(diff.mineSheet == "" && line.lineType == deletion && line.theirsPos == 0)
+}
 
-			for _, diffLine := range theirDiff {
-				htmlFile.Write([]byte(htmlAddRow(diffLine)))
-			}
+// compares the comments and rules once the rows have been matched
+func (diff *sheetDiff) compareSheetDetails(detailsTheirs cellDetails, detailsMine cellDetails) {
+	diff.commentChanges = diff.compareComments(detailsTheirs.comments, detailsMine.comments)
+	diff.ruleChanges = compareSheetRules(detailsTheirs.rules, detailsMine.rules)
 
-			htmlFile.Write([]byte(htmlEndTable()))
-		}
-		htmlFile.Write([]byte(htmlAddBreakLine()))
+	diff.counts.other += len(diff.columns.changes) + len(diff.commentChanges) + len(diff.ruleChanges)
 
+	diff.equalMessage = "Sheets are equal"
+	if len(diff.columns.changes) > 0 {
+		diff.equalMessage = "Matching columns are equal"
+	} else if len(diff.commentChanges) > 0 || len(diff.ruleChanges) > 0 {
+		diff.equalMessage = "Cell values are equal"
 	}
 }
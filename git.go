@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runs git in the given directory and returns the trimmed standard output
+func runGit(dir string, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return "", errors.New(strings.TrimSpace(stderr.String()))
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// splits a from ref written as A..B or A...B into the two refs to compare.
+// A...B compares B against the merge base of A and B like git diff does
+func resolveRefRange(dir string, from string, to string) (string, string, error) {
+	separator := ""
+	if strings.Contains(from, "...") {
+		separator = "..."
+	} else if strings.Contains(from, "..") {
+		separator = ".."
+	} else {
+		return from, to, nil
+	}
+
+	if to != "" {
+		return "", "", errors.New("-to can not be used with a " + separator + " range")
+	}
+
+	from, to, _ = strings.Cut(from, separator)
+	if from == "" {
+		from = "HEAD"
+	}
+	if to == "" {
+		to = "HEAD"
+	}
+
+	if separator == "..." {
+		base, err := runGit(dir, "merge-base", from, to)
+		if err != nil {
+			return "", "", err
+		}
+		from = base
+	}
+
+	return from, to, nil
+}
+
+// writes the file at the git path in the given commit or the index to dest.
+// Git lfs pointers are replaced with the workbook they point to
+func writeGitBlob(dir string, ref string, gitPath string, dest string) error {
+	destFile, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("git", "show", gitObject(ref, gitPath))
+	cmd.Dir = dir
+	cmd.Stdout = destFile
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	destFile.Close()
+
+	if err != nil {
+		os.Remove(dest)
+		if stderr.Len() > 0 {
+			return errors.New(strings.TrimSpace(stderr.String()))
+		}
+		return err
+	}
+
+	// git show gives the pointer for workbooks stored in git lfs
+	if err := resolveLFSPointer(dir, dest); err != nil {
+		os.Remove(dest)
+		return err
+	}
+
+	return nil
+}
+
+// writes the file at the git path in the given commit or the index to a new
+// temp file and returns its path. The pattern names the file like os.CreateTemp
+func writeTempGitBlob(dir string, ref string, gitPath string, pattern string) (string, error) {
+	tempFile, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	tempFile.Close()
+
+	if err := writeGitBlob(dir, ref, gitPath, tempFile.Name()); err != nil {
+		os.Remove(tempFile.Name())
+		return "", err
+	}
+
+	return tempFile.Name(), nil
+}
+
+// the pathspecs of the workbooks git lists for ged. Extensions are matched
+// ignoring case so Report.XLSX is a workbook too
+var workbookPathspecs = []string{":(icase)*.xlsx", ":(icase)*.xlsm"}
+
+// the ref used for the index (staged) version of a file
+const indexRef = ":"
+
+// the git object name of a file in a commit or in the index
+func gitObject(ref string, gitPath string) string {
+	gitPath = strings.ReplaceAll(gitPath, "\\", "/")
+	if ref == indexRef {
+		return ":" + gitPath
+	}
+	return ref + ":" + gitPath
+}
+
+// describes a ref for the output so it is clear which versions are compared
+func refLabel(dir string, ref string) string {
+	switch {
+	case ref == "":
+		return "the working tree"
+	case ref == indexRef:
+		return "the index (staged)"
+	case strings.HasPrefix(ref, "stash@{"):
+		if subject, err := runGit(dir, "log", "-1", "--format=%s", ref); err == nil {
+			return ref + " (" + subject + ")"
+		}
+	}
+	return ref
+}
+
+// the arguments for git diff to compare two refs, blank to is the working tree
+func gitDiffArgs(from string, to string) []string {
+	switch {
+	case from == indexRef && to == "":
+		return []string{}
+	case to == indexRef:
+		return []string{"--cached", from}
+	case to == "":
+		return []string{from}
+	default:
+		return []string{from, to}
+	}
+}
+
+// checks if the file is in the given commit
+func gitPathExists(dir string, ref string, gitPath string) bool {
+	_, err := runGit(dir, "cat-file", "-e", gitObject(ref, gitPath))
+	return err == nil
+}
+
+// finds the path a file had at the from commit when it has been renamed or
+// moved since. to is the commit the path is from, blank for the working tree.
+// Returns blank if the file was not renamed or the old path can't be found
+func findRenamedPath(dir string, from string, to string, gitPath string) string {
+	gitPath = filepath.ToSlash(gitPath)
+	if from == "" || gitPathExists(dir, from, gitPath) {
+		return ""
+	}
+
+	args := append([]string{"-c", "core.quotePath=false", "diff", "-M", "--name-status"}, gitDiffArgs(from, to)...)
+
+	if output, err := runGit(dir, args...); err == nil {
+		for _, line := range strings.Split(output, "\n") {
+			fields := strings.Split(line, "\t")
+			if len(fields) == 3 && strings.HasPrefix(fields[0], "R") && fields[2] == gitPath {
+				return fields[1]
+			}
+		}
+	}
+
+	// the rename is not in the diff when the new file is not committed yet or
+	// changed too much, so follow the history of the file instead
+	if to == "" || to == indexRef {
+		to = "HEAD"
+	}
+
+	output, err := runGit(dir, "-c", "core.quotePath=false", "log", "--follow", "-M", "--name-status", "--format=", to, "--", gitPath)
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) == 3 && strings.HasPrefix(fields[0], "R") && gitPathExists(dir, from, fields[1]) {
+			return fields[1]
+		}
+	}
+
+	return ""
+}
+
+// the command git should use to start ged. Uses ged from the path if it is
+// there so the config keeps working after ged is updated
+func gedCommand() string {
+	if _, err := exec.LookPath("ged"); err == nil {
+		return "ged"
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return "ged"
+	}
+
+	return "\"" + filepath.ToSlash(executable) + "\""
+}
+
+// registers ged as a git difftool in the global git config so that
+// git difftool -t ged works in every repo
+func setupDifftool() error {
+	if _, err := runGit("", "config", "--global", "difftool.ged.cmd", gedCommand()+" \"$LOCAL\" \"$REMOTE\""); err != nil {
+		return err
+	}
+
+	if _, err := runGit("", "config", "--global", "difftool.ged.trustExitCode", "true"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// registers ged as the git diff textconv driver for diff=ged in .gitattributes
+func setupTextconv() error {
+	_, err := runGit("", "config", "--global", "diff.ged.textconv", gedCommand()+" textconv")
+	return err
+}
+
+// registers ged as the git merge driver for merge=ged in .gitattributes
+func setupMerge() error {
+	if _, err := runGit("", "config", "--global", "merge.ged.name", "ged excel workbook merge"); err != nil {
+		return err
+	}
+
+	_, err := runGit("", "config", "--global", "merge.ged.driver", gedCommand()+" merge %O %A %B")
+	return err
+}
+
+// the first line after the shebang of the hooks ged writes
+const gedHookMarker = "# installed by ged hook install"
+
+// writes a pre-commit hook that runs ged check on the staged workbooks. A hook
+// that ged did not write is only replaced when force is set
+func installPreCommitHook(force bool) (string, error) {
+	hookPath, err := runGit("", "rev-parse", "--git-path", "hooks/pre-commit")
+	if err != nil {
+		return "", err
+	}
+	hookPath = filepath.FromSlash(hookPath)
+
+	if existing, err := os.ReadFile(hookPath); err == nil && !force && !strings.Contains(string(existing), gedHookMarker) {
+		return "", errors.New("a pre-commit hook already exists at " + hookPath + ", use -f to replace it")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+		return "", err
+	}
+
+	hook := "#!/bin/sh\n" + gedHookMarker + "\nexec " + gedCommand() + " check --staged\n"
+	if err := os.WriteFile(hookPath, []byte(hook), 0755); err != nil {
+		return "", err
+	}
+
+	return hookPath, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// a new git repository in a temp directory that does not use the user's git config
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "ged")
+	t.Setenv("GIT_AUTHOR_EMAIL", "ged@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "ged")
+	t.Setenv("GIT_COMMITTER_EMAIL", "ged@example.com")
+
+	dir := t.TempDir()
+	testGit(t, dir, "init", "-q", "-b", "main")
+	return dir
+}
+
+func testGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	output, err := runGit(dir, args...)
+	if err != nil {
+		t.Fatalf("git %v: %s", args, err)
+	}
+	return output
+}
+
+func writeTestFile(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commits every file in the repository and returns the commit hash
+func testCommit(t *testing.T, dir string, message string) string {
+	t.Helper()
+
+	testGit(t, dir, "add", "-A")
+	testGit(t, dir, "commit", "-q", "-m", message)
+	return testGit(t, dir, "rev-parse", "HEAD")
+}
+
+func TestResolveRefRange(t *testing.T) {
+	dir := newTestRepo(t)
+
+	writeTestFile(t, dir, "a.txt", "base\n")
+	base := testCommit(t, dir, "base")
+	testGit(t, dir, "checkout", "-q", "-b", "feature")
+	writeTestFile(t, dir, "a.txt", "feature\n")
+	feature := testCommit(t, dir, "feature")
+	testGit(t, dir, "checkout", "-q", "main")
+	writeTestFile(t, dir, "b.txt", "main\n")
+	testCommit(t, dir, "main")
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want [2]string
+		err  bool
+	}{
+		{name: "single ref", from: "main", want: [2]string{"main", ""}},
+		{name: "single ref with to", from: "main", to: "feature", want: [2]string{"main", "feature"}},
+		{name: "two dots", from: "main..feature", want: [2]string{"main", "feature"}},
+		{name: "two dots without from", from: "..feature", want: [2]string{"HEAD", "feature"}},
+		{name: "two dots without to", from: "feature..", want: [2]string{"feature", "HEAD"}},
+		{name: "three dots uses the merge base", from: "main...feature", want: [2]string{base, "feature"}},
+		{name: "three dots without to", from: "feature...", want: [2]string{base, "HEAD"}},
+		{name: "range with to", from: "main..feature", to: "HEAD", err: true},
+		{name: "three dots with unknown ref", from: "main...missing", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from, to, err := resolveRefRange(dir, test.from, test.to)
+			if test.err {
+				if err == nil {
+					t.Errorf("got %s %s, want an error", from, to)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if [2]string{from, to} != test.want {
+				t.Errorf("got %s %s, want %s %s", from, to, test.want[0], test.want[1])
+			}
+		})
+	}
+
+	// the merge base is the same commit whichever branch is checked out
+	if from, _, _ := resolveRefRange(dir, feature+"...main", ""); from != base {
+		t.Errorf("merge base %s, want %s", from, base)
+	}
+}
+
+func TestFindRenamedPath(t *testing.T) {
+	dir := newTestRepo(t)
+
+	content := "ID,Name\n1,a\n2,b\n3,c\n4,d\n5,e\n"
+	writeTestFile(t, dir, "book.xlsx", content)
+	writeTestFile(t, dir, "same.xlsx", "same\n")
+	first := testCommit(t, dir, "first")
+
+	if err := os.Mkdir(filepath.Join(dir, "reports"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	testGit(t, dir, "mv", "book.xlsx", "reports/book.xlsx")
+	moved := testCommit(t, dir, "move")
+
+	// a rename with a change that is too big for git diff to find on its own
+	testGit(t, dir, "mv", "reports/book.xlsx", "reports/renamed.xlsx")
+	testCommit(t, dir, "rename")
+	writeTestFile(t, dir, "reports/renamed.xlsx", "completely different\n")
+	testCommit(t, dir, "rewrite")
+
+	writeTestFile(t, dir, "new.xlsx", "new\n")
+	testCommit(t, dir, "new")
+
+	// a rename that is only in the index
+	testGit(t, dir, "mv", "same.xlsx", "staged.xlsx")
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		gitPath string
+		want    string
+	}{
+		{name: "moved between commits", from: first, to: moved, gitPath: "reports/book.xlsx", want: "book.xlsx"},
+		{name: "renamed and rewritten", from: first, gitPath: filepath.FromSlash("reports/renamed.xlsx"), want: "book.xlsx"},
+		{name: "renamed since a later commit", from: moved, gitPath: "reports/renamed.xlsx", want: "reports/book.xlsx"},
+		{name: "renamed in the index", from: "HEAD", to: indexRef, gitPath: "staged.xlsx", want: "same.xlsx"},
+		{name: "not renamed", from: first, gitPath: "same.xlsx", want: ""},
+		{name: "new file", from: first, gitPath: "new.xlsx", want: ""},
+		{name: "no from commit", from: "", gitPath: "reports/renamed.xlsx", want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := findRenamedPath(dir, test.from, test.to, test.gitPath); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
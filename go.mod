@@ -2,11 +2,13 @@ module github.com/abunker97/ged
 
 go 1.22.2
 
-require github.com/xuri/excelize/v2 v2.8.1
+require (
+	github.com/mxschmitt/golang-combinations v1.2.0
+	github.com/xuri/excelize/v2 v2.8.1
+)
 
 require (
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
-	github.com/mxschmitt/golang-combinations v1.2.0 // indirect
 	github.com/richardlehane/mscfb v1.0.4 // indirect
 	github.com/richardlehane/msoleps v1.0.3 // indirect
 	github.com/xuri/efp v0.0.0-20231025114914-d1ff6096ae53 // indirect
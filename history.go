@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// a commit that added, changed or removed the row
+type rowHistoryEntry struct {
+	version    int
+	changeType differenceType
+	cells      []rowHistoryCell
+	reason     string // why the row may not be the right one, blank if the key is unique
+}
+
+// a cell of the row before and after the commit
+type rowHistoryCell struct {
+	column string
+	theirs string
+	mine   string
+}
+
+func historyUsage() {
+	fmt.Printf("Usage: ged history [arguments] <excel workbook> -sheet <sheet> -key <column>=<value>[,<column>=<value>]\n")
+	fmt.Printf("Lists every commit that added, changed or removed the row with the key\n")
+}
+
+// ged history lists the commits that changed one row of a sheet. The row is
+// found in each version by the values of its key columns
+func runHistory(args []string) {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	var sheetFlag = flags.String("sheet", "", "The sheet the row is on")
+	var keyFlag = flags.String("key", "", "The key of the row as <column>=<value>, separate multiple key columns with commas")
+	var outputFlag = flags.String("o", "", "Path to directory where the output file should go. Default is the current working directory")
+	var verboseFlag = flags.Bool("v", false, "Display verbose output")
+	flags.Usage = func() {
+		historyUsage()
+		flags.PrintDefaults()
+	}
+
+	// flags can come after the workbook so keep parsing past positional arguments
+	var positional []string
+	for {
+		flags.Parse(args)
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	verboseOutput = *verboseFlag
+
+	if len(positional) != 1 || *sheetFlag == "" || *keyFlag == "" {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	keyValues, err := parseHistoryKey(*keyFlag)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	currentDir, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Unable to find current directory. Aborting..\r\n")
+		panic(err)
+	}
+
+	gitRoot, err := runGit("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		fmt.Printf("Error: Unable to find git root folder: %s\n", err)
+		os.Exit(1)
+	}
+	gitRoot = filepath.FromSlash(gitRoot) + string(filepath.Separator)
+
+	workBookFullPath := absolutePath(currentDir, positional[0])
+	workBookGitPath := filepath.ToSlash(strings.ReplaceAll(workBookFullPath, gitRoot, ""))
+
+	versions, err := workbookVersions(gitRoot, workBookGitPath, workBookFullPath)
+	if err != nil {
+		fmt.Printf("Error: Unable to read the history of %s: %s\n", workBookGitPath, err)
+		os.Exit(1)
+	}
+
+	if len(versions) == 0 {
+		fmt.Printf("Error: %s is not in the git history\n", workBookGitPath)
+		os.Exit(1)
+	}
+
+	tempDir, err := os.MkdirTemp("", "ged-history")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	_, versionData := readBlameVersions(gitRoot, tempDir, versions, *sheetFlag)
+
+	var rows [][]string
+	var headers [][]string
+	var reasons []string
+	for index, data := range versionData {
+		header, row, reason := findKeyedRow(data[*sheetFlag], keyValues)
+		if reason != "" {
+			fmt.Printf("%s. Using the first row with the key in %s\r\n", reason, versions[index].commit.shortHash())
+		}
+		headers = append(headers, header)
+		rows = append(rows, row)
+		reasons = append(reasons, reason)
+	}
+
+	entries := rowHistory(headers, rows)
+	for index := range entries {
+		entries[index].reason = reasons[entries[index].version]
+	}
+
+	fmt.Printf("History of %s in %s of %s\n\n", *keyFlag, *sheetFlag, workBookGitPath)
+	if len(entries) == 0 {
+		fmt.Printf("Row not found in any version\n")
+	}
+
+	for _, entry := range entries {
+		commit := versions[entry.version].commit
+		fmt.Println(strings.TrimSpace(fmt.Sprintf("%s %s %s %s", commit.shortHash(), commit.date, commit.author, commit.summary)))
+		fmt.Printf("    %s\n", rowChangeLabel(entry.changeType))
+		if entry.reason != "" {
+			fmt.Printf("    %s, using the first row with the key\n", entry.reason)
+		}
+		for _, cell := range entry.cells {
+			switch entry.changeType {
+			case addition:
+				fmt.Printf("    %s: %s\n", cell.column, textconvValue(cell.mine))
+			case deletion:
+				fmt.Printf("    %s: %s\n", cell.column, textconvValue(cell.theirs))
+			default:
+				fmt.Printf("    %s: %s -> %s\n", cell.column, textconvValue(cell.theirs), textconvValue(cell.mine))
+			}
+		}
+		fmt.Println()
+	}
+
+	outputFilePath := filepath.Join(currentDir, filepath.FromSlash(*outputFlag), workbookName(workBookFullPath)+"-history.html")
+	if err := os.WriteFile(outputFilePath, []byte(htmlAddRowHistory(*sheetFlag, *keyFlag, entries, versions)), 0644); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("History written to %s\r\n", outputFilePath)
+}
+
+// parses a key like ID=1234 or Region=West,ID=1234 into column names and values
+func parseHistoryKey(spec string) (map[string]string, error) {
+	keyValues := make(map[string]string)
+
+	for _, part := range strings.Split(spec, ",") {
+		column, value, found := strings.Cut(part, "=")
+		if !found || strings.TrimSpace(column) == "" {
+			return nil, errors.New("invalid key " + spec + ", expected <column>=<value>")
+		}
+		keyValues[strings.TrimSpace(column)] = value
+	}
+
+	return keyValues, nil
+}
+
+// finds the row whose key columns have the key values. Returns the header and
+// the row, or a nil row when the sheet has no such row. When more than one row
+// has the key the first one is returned with the reason it may be the wrong row
+func findKeyedRow(data [][]string, keyValues map[string]string) ([]string, []string, string) {
+	if len(data) == 0 {
+		return nil, nil, ""
+	}
+
+	headerRow := detectHeaderRow(data)
+	header := data[headerRow]
+
+	var keyNames []string
+	for name := range keyValues {
+		keyNames = append(keyNames, name)
+	}
+
+	keyIndexes := findPrimaryKeyIndexes(header, keyNames)
+	if len(keyIndexes) != len(keyNames) {
+		return header, nil, ""
+	}
+
+	// the key columns are in header order so build the key the same way
+	key := make([]string, len(header))
+	for _, index := range keyIndexes {
+		key[index] = keyValues[header[index]]
+	}
+	keyString := concatKeysData(key, keyIndexes)
+
+	var matches [][]string
+	for _, row := range data[headerRow+1:] {
+		if concatKeysData(row, keyIndexes) == keyString {
+			matches = append(matches, row)
+		}
+	}
+
+	if len(matches) == 0 {
+		return header, nil, ""
+	}
+
+	reason := ""
+	if err := primaryKeysUnique(matches, keyIndexes); err != nil {
+		reason = "Primary key is not unique: " + readableKeyError(err)
+	}
+
+	return header, matches[0], reason
+}
+
+// compares the row in each version with the version before it
+func rowHistory(headers [][]string, rows [][]string) []rowHistoryEntry {
+	var entries []rowHistoryEntry
+
+	for index := range rows {
+		var previousHeader, previousRow []string
+		if index > 0 {
+			previousHeader = headers[index-1]
+			previousRow = rows[index-1]
+		}
+
+		switch {
+		case previousRow == nil && rows[index] == nil:
+			continue
+		case previousRow == nil:
+			entries = append(entries, rowHistoryEntry{version: index, changeType: addition, cells: rowHistoryCells(nil, nil, headers[index], rows[index], true)})
+		case rows[index] == nil:
+			entries = append(entries, rowHistoryEntry{version: index, changeType: deletion, cells: rowHistoryCells(previousHeader, previousRow, nil, nil, true)})
+		default:
+			cells := rowHistoryCells(previousHeader, previousRow, headers[index], rows[index], false)
+			if len(cells) > 0 {
+				entries = append(entries, rowHistoryEntry{version: index, changeType: difference, cells: cells})
+			}
+		}
+	}
+
+	return entries
+}
+
+// pairs the cells of the two versions of the row by column name. Only cells
+// that changed are returned unless all is set
+func rowHistoryCells(theirsHeader, theirsRow, mineHeader, mineRow []string, all bool) []rowHistoryCell {
+	var cells []rowHistoryCell
+
+	theirsValues := make(map[string]string)
+	for index, column := range theirsHeader {
+		if _, ok := theirsValues[column]; !ok {
+			theirsValues[column] = cellAt(theirsRow, index)
+		}
+	}
+
+	mineColumns := make(map[string]bool)
+	for index, column := range mineHeader {
+		if mineColumns[column] {
+			continue
+		}
+		mineColumns[column] = true
+
+		if all || theirsValues[column] != cellAt(mineRow, index) {
+			cells = append(cells, rowHistoryCell{column: column, theirs: theirsValues[column], mine: cellAt(mineRow, index)})
+		}
+	}
+
+	// columns that were removed
+	for _, column := range theirsHeader {
+		if !mineColumns[column] && (all || theirsValues[column] != "") {
+			mineColumns[column] = true
+			cells = append(cells, rowHistoryCell{column: column, theirs: theirsValues[column]})
+		}
+	}
+
+	return cells
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindKeyedRow(t *testing.T) {
+	data := [][]string{
+		{"Report"},
+		{"Region", "ID", "Name"},
+		{"West", "1", "a"},
+		{"East", "1", "b"},
+		{"West", "2", "c"},
+		{"West", "2", "d"},
+	}
+
+	tests := []struct {
+		name   string
+		key    map[string]string
+		row    []string
+		reason string
+	}{
+		{name: "unique key", key: map[string]string{"Region": "East", "ID": "1"}, row: []string{"East", "1", "b"}},
+		{name: "key not found", key: map[string]string{"ID": "3"}},
+		{name: "unknown column", key: map[string]string{"Code": "1"}},
+		{name: "duplicate key", key: map[string]string{"ID": "1"}, row: []string{"West", "1", "a"}, reason: "Primary key is not unique: key 1 found multiple times"},
+		{name: "duplicate compound key", key: map[string]string{"Region": "West", "ID": "2"}, row: []string{"West", "2", "c"}, reason: "Primary key is not unique: key West, 2 found multiple times"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header, row, reason := findKeyedRow(data, test.key)
+			if !reflect.DeepEqual(header, data[1]) {
+				t.Errorf("header %q, want %q", header, data[1])
+			}
+			if !reflect.DeepEqual(row, test.row) {
+				t.Errorf("row %q, want %q", row, test.row)
+			}
+			if reason != test.reason {
+				t.Errorf("reason %q, want %q", reason, test.reason)
+			}
+		})
+	}
+}
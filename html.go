@@ -1,6 +1,9 @@
 package main
 
-import ()
+import (
+	"html"
+	"strconv"
+)
 
 func htmlAddSheetHeader(sheetName string) string {
 	return "<header align=\"center\"><h1>" + sheetName + "</h1></header>"
@@ -22,22 +25,97 @@ func htmlAddBreakLine() string {
 	return "<hr>\n"
 }
 
-func htmlAddDiffRow(theirsDataMap, mineDataMap map[string][]string, diffLine differenceLine) string {
-	rowString := "<tr>"
+// the html for the differences between two workbooks
+func htmlWorkbookDiff(diff workbookDiff) string {
+	diffString := htmlAddDiffHeader(diff.header)
+
+	if len(diff.sheetChanges) > 0 {
+		diffString += htmlAddWorkbookChanges(diff.sheetChanges)
+	}
+
+	if len(diff.definedNameChanges) > 0 {
+		diffString += htmlAddDefinedNameChanges(diff.definedNameChanges)
+	}
+
+	for _, sheet := range diff.sheets {
+		diffString += htmlSheetDiff(sheet)
+	}
+
+	return diffString
+}
+
+// the html for the differences in one sheet
+func htmlSheetDiff(diff sheetDiff) string {
+	diffString := htmlAddSheetHeader(diff.name)
+
+	if !regionIsWholeSheet(diff.theirsRegion) || !regionIsWholeSheet(diff.mineRegion) {
+		diffString += htmlAddRegions(diff.theirsRegion, diff.mineRegion)
+	}
+
+	if len(diff.columns.changes) > 0 {
+		diffString += htmlAddColumnChanges(diff.columns.changes)
+	}
+
+	if len(diff.commentChanges) > 0 {
+		diffString += htmlAddCommentChanges(diff.commentChanges)
+	}
+
+	if len(diff.ruleChanges) > 0 {
+		diffString += htmlAddRuleChanges(diff.ruleChanges)
+	}
+
+	if len(diff.lines) == 0 && len(diff.hunks) == 0 {
+		diffString += htmlAddSubHeading(diff.equalMessage)
+		diffString += htmlAddBreakLine()
+		return diffString
+	}
+
+	diffString += htmlStartTable()
+	diffString += htmlAddTableHeaderDiff(diff.header)
+
+	for _, diffLine := range diff.lines {
+		diffString += htmlAddDiffRow(diff.dataTheirs, diff.dataMine, diff.detailsTheirs, diff.detailsMine, diffLine)
+	}
+
+	for _, hunk := range diff.hunks {
+		diffString += htmlAddHunkHeader(hunk, len(diff.header))
+
+		for _, diffLine := range hunk.lines {
+			diffString += htmlAddDiffRow(diff.dataTheirs, diff.dataMine, diff.detailsTheirs, diff.detailsMine, diffLine)
+		}
+	}
+
+	diffString += htmlEndTable()
+	diffString += htmlAddBreakLine()
 
-	// handle if line is an addition
+	return diffString
+}
+
+func htmlAddDiffRow(dataTheirs, dataMine [][]string, detailsTheirs, detailsMine cellDetails, diffLine differenceLine) string {
+	// handle if line is an addition or deletion
 	if diffLine.lineType == addition {
-		return htmlAddNewRow(mineDataMap, diffLine.key)
+		return htmlAddNewRow(dataMine, detailsMine, diffLine)
 	}
 
-	theirRow := theirsDataMap[diffLine.key]
-	mineRow := mineDataMap[diffLine.key]
+	if diffLine.lineType == deletion {
+		return htmlAddDeletedRow(dataTheirs, detailsTheirs, diffLine)
+	}
+
+	rowString := "<tr>"
+	rowString += htmlAddChangeLabel(diffLine.lineType)
+	rowString += htmlAddRowNumbers(diffLine)
+
+	theirRow := dataTheirs[diffLine.theirsPos]
+	mineRow := dataMine[diffLine.minePos]
 
-	for index, cell := range theirRow {
-		if mineRow == nil {
-			rowString += "<td align=\"center\" style=\"padding:10px\"><font color=\"red\">" + cell + "</font></td>"
-		} else if cell != mineRow[index] {
-			rowString += "<td align=\"center\" style=\"padding:10px\"><font color=\"red\">" + cell + "</font><br /><font color=\"green\">" + mineRow[index] + "</font></td>"
+	for index := 0; index < max(len(theirRow), len(mineRow)); index++ {
+		cell := htmlCellContent(cellAt(theirRow, index), detailsTheirs, diffLine.theirsPos, index)
+		mineCell := htmlCellContent(cellAt(mineRow, index), detailsMine, diffLine.minePos, index)
+		if cellContentDiffers(dataTheirs, dataMine, detailsTheirs, detailsMine, diffLine.theirsPos, diffLine.minePos, index) {
+			rowString += "<td align=\"center\" style=\"padding:10px\"><font color=\"red\">" + cell + "</font><br /><font color=\"green\">" + mineCell + "</font>" +
+				htmlStyleChange(detailsTheirs, detailsMine, diffLine, index) + "</td>"
+		} else if cellStyleDiffers(detailsTheirs, detailsMine, diffLine.theirsPos, diffLine.minePos, index) {
+			rowString += "<td align=\"center\" style=\"padding:10px;background-color:#fff5cc\">" + cell + htmlStyleChange(detailsTheirs, detailsMine, diffLine, index) + "</td>"
 		} else {
 			rowString += "<td align=\"center\" style=\"padding:10px\">" + cell + "</td>"
 		}
@@ -48,13 +126,27 @@ func htmlAddDiffRow(theirsDataMap, mineDataMap map[string][]string, diffLine dif
 	return rowString
 }
 
-func htmlAddNewRow(mineDataMap map[string][]string, key string) string {
-	rowString := "<tr>"
+func htmlAddNewRow(dataMine [][]string, detailsMine cellDetails, diffLine differenceLine) string {
+	rowString := "<tr style=\"background-color:#e6ffe6\">"
+	rowString += htmlAddChangeLabel(addition)
+	rowString += htmlAddRowNumbers(diffLine)
+
+	for index, cell := range dataMine[diffLine.minePos] {
+		rowString += "<td align=\"center\" style=\"padding:10px\"><font color=\"green\">" + htmlCellContent(cell, detailsMine, diffLine.minePos, index) + "</font></td>"
+	}
 
-	mineRow := mineDataMap[key]
+	rowString += "</tr>\n"
 
-	for _, cell := range mineRow {
-		rowString += "<td align=\"center\" style=\"padding:10px\"><font color=\"green\">" + cell + "</font></td>"
+	return rowString
+}
+
+func htmlAddDeletedRow(dataTheirs [][]string, detailsTheirs cellDetails, diffLine differenceLine) string {
+	rowString := "<tr style=\"background-color:#ffe6e6\">"
+	rowString += htmlAddChangeLabel(deletion)
+	rowString += htmlAddRowNumbers(diffLine)
+
+	for index, cell := range dataTheirs[diffLine.theirsPos] {
+		rowString += "<td align=\"center\" style=\"padding:10px\"><font color=\"red\"><s>" + htmlCellContent(cell, detailsTheirs, diffLine.theirsPos, index) + "</s></font></td>"
 	}
 
 	rowString += "</tr>\n"
@@ -62,6 +154,59 @@ func htmlAddNewRow(mineDataMap map[string][]string, key string) string {
 	return rowString
 }
 
+// the parts of the cell style that changed, blank if the style is the same
+func htmlStyleChange(detailsTheirs, detailsMine cellDetails, diffLine differenceLine, col int) string {
+	if !cellStyleDiffers(detailsTheirs, detailsMine, diffLine.theirsPos, diffLine.minePos, col) {
+		return ""
+	}
+
+	theirsStyle, mineStyle := styleDifference(detailAt(detailsTheirs.styles, diffLine.theirsPos, col), detailAt(detailsMine.styles, diffLine.minePos, col))
+
+	return "<br /><small><font color=\"red\">" + html.EscapeString(theirsStyle) + "</font><br /><font color=\"green\">" + html.EscapeString(mineStyle) + "</font></small>"
+}
+
+// the value of a cell followed by its formula if it has one
+func htmlCellContent(value string, details cellDetails, pos int, col int) string {
+	formula := detailAt(details.formulas, pos, col)
+	if formula == "" {
+		return value
+	}
+	return value + "<br /><code>" + html.EscapeString(formula) + "</code>"
+}
+
+// row numbers of the line in theirs and mine. Blank when the row is missing from that side
+func htmlAddRowNumbers(diffLine differenceLine) string {
+	rowString := ""
+
+	for _, row := range []int{diffLine.theirsRow, diffLine.mineRow} {
+		if row == 0 {
+			rowString += "<td align=\"center\" style=\"padding:10px\"></td>"
+		} else {
+			rowString += "<td align=\"center\" style=\"padding:10px\"><i>" + strconv.Itoa(row) + "</i></td>"
+		}
+	}
+
+	return rowString
+}
+
+func htmlAddHunkHeader(hunk diffHunk, columns int) string {
+	return "<tr><td colspan=\"" + strconv.Itoa(columns+3) + "\" style=\"padding:10px;background-color:#eeeeee\"><b>Theirs " +
+		hunkRangeString(hunk.theirsStart, hunk.theirsCount) + " / Mine " + hunkRangeString(hunk.mineStart, hunk.mineCount) + "</b></td></tr>\n"
+}
+
+func htmlAddChangeLabel(lineType differenceType) string {
+	switch lineType {
+	case addition:
+		return "<td align=\"center\" style=\"padding:10px\"><b><font color=\"green\">Row added</font></b></td>"
+	case deletion:
+		return "<td align=\"center\" style=\"padding:10px\"><b><font color=\"red\">Row removed</font></b></td>"
+	case styleChange:
+		return "<td align=\"center\" style=\"padding:10px\"><b><font color=\"#b36b00\">Style changed</font></b></td>"
+	default:
+		return "<td align=\"center\" style=\"padding:10px\"><b>Row changed</b></td>"
+	}
+}
+
 func htmlAddRow(rowData []string) string {
 	rowString := "<tr>"
 
@@ -98,3 +243,289 @@ func htmlAddTableHeaderDefaultDiff(rowData []string) string {
 
 	return rowString
 }
+
+func htmlAddTableHeaderDiff(rowData []string) string {
+	rowString := "<tr>"
+	rowString += "<td align=\"center\" style=\"padding:10px\"><b>Change</b></td>"
+	rowString += "<td align=\"center\" style=\"padding:10px\"><b>Theirs Row</b></td>"
+	rowString += "<td align=\"center\" style=\"padding:10px\"><b>Mine Row</b></td>"
+
+	for _, cell := range rowData {
+		rowString += "<td align=\"center\" style=\"padding:10px\"><b>" + cell + "</b></td>"
+	}
+
+	rowString += "</tr>\n"
+
+	return rowString
+}
+
+func htmlAddColumnChanges(changes []columnChange) string {
+	tableString := htmlAddSubHeading("Column changes")
+	tableString += htmlStartTable()
+	tableString += htmlAddTableHeaderDefaultDiff([]string{"Change", "Column", "Theirs Column", "Mine Column"})
+
+	for _, change := range changes {
+		name := change.name
+		if change.changeType == columnRenamed {
+			name = "<font color=\"red\">" + change.oldName + "</font><br /><font color=\"green\">" + change.name + "</font>"
+		}
+
+		tableString += htmlAddRow([]string{"<b>" + columnChangeString(change) + "</b>", name, columnLetter(change.theirsCol), columnLetter(change.mineCol)})
+	}
+
+	tableString += htmlEndTable()
+
+	return tableString
+}
+
+func htmlAddRegions(theirsRegion, mineRegion sheetRegion) string {
+	return "<p align=\"center\">Theirs: " + regionString(theirsRegion) + "<br />Mine: " + regionString(mineRegion) + "</p>\n"
+}
+
+func htmlAddCommentChanges(changes []commentChange) string {
+	tableString := htmlAddSubHeading("Comment changes")
+	tableString += htmlStartTable()
+	tableString += htmlAddTableHeaderDefaultDiff([]string{"Change", "Cell", "Theirs", "Mine"})
+
+	for _, change := range changes {
+		theirsComment := ""
+		if change.changeType != addition {
+			theirsComment = "<font color=\"red\">" + html.EscapeString(commentString(change.theirs)) + "</font>"
+		}
+
+		mineComment := ""
+		if change.changeType != deletion {
+			mineComment = "<font color=\"green\">" + html.EscapeString(commentString(change.mine)) + "</font>"
+		}
+
+		tableString += htmlAddRow([]string{"<b>" + commentChangeString(change) + "</b>", commentCellString(change), theirsComment, mineComment})
+	}
+
+	tableString += htmlEndTable()
+
+	return tableString
+}
+
+func htmlAddWorkbookChanges(changes []sheetChange) string {
+	tableString := htmlAddSheetHeader("Workbook")
+	tableString += htmlStartTable()
+	tableString += htmlAddTableHeaderDefaultDiff([]string{"Change", "Theirs Sheet", "Mine Sheet", "Theirs Position", "Mine Position", "Details"})
+
+	for _, change := range changes {
+		details := ""
+		switch change.changeType {
+		case sheetRenamed:
+			details = strconv.Itoa(int(change.similarity*100)) + "% of rows match"
+		case sheetVisibilityChanged:
+			details = "<font color=\"red\">" + change.theirsState + "</font> to <font color=\"green\">" + change.mineState + "</font>"
+		}
+
+		tableString += htmlAddRow([]string{"<b>" + sheetChangeString(change) + "</b>", change.theirsName, change.mineName,
+			sheetPositionString(change.theirsPos), sheetPositionString(change.minePos), details})
+	}
+
+	tableString += htmlEndTable()
+	tableString += htmlAddBreakLine()
+
+	return tableString
+}
+
+func sheetPositionString(pos int) string {
+	if pos < 0 {
+		return ""
+	}
+	return strconv.Itoa(pos + 1)
+}
+
+func htmlAddDefinedNameChanges(changes []definedNameChange) string {
+	tableString := htmlAddSheetHeader("Defined Names")
+	tableString += htmlStartTable()
+	tableString += htmlAddTableHeaderDefaultDiff([]string{"Change", "Name", "Scope", "Refers To", "Comment"})
+
+	for _, change := range changes {
+		name := definedNameChangeName(change)
+
+		refersTo := html.EscapeString(name.RefersTo)
+		comment := html.EscapeString(name.Comment)
+		switch change.changeType {
+		case addition:
+			refersTo = "<font color=\"green\">" + refersTo + "</font>"
+			comment = "<font color=\"green\">" + comment + "</font>"
+		case deletion:
+			refersTo = "<font color=\"red\"><s>" + refersTo + "</s></font>"
+			comment = "<font color=\"red\"><s>" + comment + "</s></font>"
+		default:
+			refersTo = htmlAddChangedValue(change.theirs.RefersTo, change.mine.RefersTo)
+			comment = htmlAddChangedValue(change.theirs.Comment, change.mine.Comment)
+		}
+
+		tableString += htmlAddRow([]string{"<b>" + definedNameChangeString(change) + "</b>", html.EscapeString(name.Name), html.EscapeString(name.Scope), refersTo, comment})
+	}
+
+	tableString += htmlEndTable()
+	tableString += htmlAddBreakLine()
+
+	return tableString
+}
+
+// shows the old and new value if they are different
+func htmlAddChangedValue(theirsValue, mineValue string) string {
+	if theirsValue == mineValue {
+		return html.EscapeString(mineValue)
+	}
+	return "<font color=\"red\">" + html.EscapeString(theirsValue) + "</font><br /><font color=\"green\">" + html.EscapeString(mineValue) + "</font>"
+}
+
+func htmlAddRuleChanges(changes []ruleChange) string {
+	tableString := htmlAddSubHeading("Sheet rules")
+	tableString += htmlStartTable()
+	tableString += htmlAddTableHeaderDefaultDiff([]string{"Change", "Range", "Theirs", "Mine"})
+
+	for _, change := range changes {
+		theirsRule := ""
+		if change.changeType != addition {
+			theirsRule = "<font color=\"red\">" + html.EscapeString(change.theirs) + "</font>"
+		}
+
+		mineRule := ""
+		if change.changeType != deletion {
+			mineRule = "<font color=\"green\">" + html.EscapeString(change.mine) + "</font>"
+		}
+
+		tableString += htmlAddRow([]string{"<b>" + ruleChangeString(change) + "</b>", htmlAddChangedValue(change.theirsRanges, change.mineRanges), theirsRule, mineRule})
+	}
+
+	tableString += htmlEndTable()
+
+	return tableString
+}
+
+func htmlAddDiffHeader(header diffHeader) string {
+	headerString := ""
+
+	if header.theirs != "" || header.mine != "" {
+		headerString += htmlAddSubHeading("Mine: " + html.EscapeString(header.mine) + "<br>Theirs: " + html.EscapeString(header.theirs))
+	}
+
+	if header.rename.from != "" {
+		headerString += htmlAddSubHeading("Renamed from " + html.EscapeString(header.rename.from) + " to " + html.EscapeString(header.rename.to))
+	}
+
+	if headerString != "" {
+		headerString += htmlAddBreakLine()
+	}
+
+	return headerString
+}
+
+func htmlAddRangeIndex(revRange string, files []rangeFile) string {
+	tableString := htmlAddSheetHeader("Workbooks changed in " + html.EscapeString(revRange))
+
+	if len(files) == 0 {
+		tableString += htmlAddSubHeading("No workbooks changed")
+		return tableString
+	}
+
+	tableString += htmlStartTable()
+	tableString += htmlAddTableHeaderDefaultDiff([]string{"Workbook", "Change", "Rows Added", "Rows Removed", "Rows Changed", "Other Changes"})
+
+	var total diffCounts
+	for _, file := range files {
+		link := "<a href=\"" + html.EscapeString(file.report) + "\">" + html.EscapeString(file.path) + "</a>"
+		status := file.status
+		if file.oldPath != "" {
+			status += " from " + html.EscapeString(file.oldPath)
+		}
+
+		// workbooks that could not be diffed have no report to link to
+		if file.err != "" {
+			tableString += htmlAddRow([]string{html.EscapeString(file.path), status + ", <font color=\"red\">" + html.EscapeString(file.err) + "</font>", "", "", "", ""})
+			continue
+		}
+
+		tableString += htmlAddRow([]string{link, status, strconv.Itoa(file.counts.added), strconv.Itoa(file.counts.removed),
+			strconv.Itoa(file.counts.changed), strconv.Itoa(file.counts.other)})
+		total.add(file.counts)
+	}
+
+	tableString += htmlAddRow([]string{"<b>Total</b>", strconv.Itoa(len(files)) + " workbooks", strconv.Itoa(total.added), strconv.Itoa(total.removed),
+		strconv.Itoa(total.changed), strconv.Itoa(total.other)})
+
+	tableString += htmlEndTable()
+	tableString += htmlAddBreakLine()
+
+	return tableString
+}
+
+func htmlAddBlame(sheet string, cells []blameCell, versions []blameVersion, rows bool) string {
+	tableString := htmlAddSheetHeader(sheet)
+
+	if len(cells) == 0 {
+		tableString += htmlAddSubHeading("No cells to blame")
+		tableString += htmlAddBreakLine()
+		return tableString
+	}
+
+	location := "Cell"
+	if rows {
+		location = "Row"
+	}
+
+	tableString += htmlStartTable()
+	tableString += htmlAddTableHeaderDefaultDiff([]string{location, "Key", "Value", "Commit", "Author", "Date", "Summary"})
+
+	for _, cell := range cells {
+		commit := versions[cell.version].commit
+		tableString += htmlAddRow([]string{cell.cell, html.EscapeString(cell.key), html.EscapeString(cell.value), "<code>" + commit.shortHash() + "</code>",
+			html.EscapeString(commit.author), commit.date, html.EscapeString(commit.summary)})
+	}
+
+	tableString += htmlEndTable()
+	tableString += htmlAddBreakLine()
+
+	return tableString
+}
+
+func htmlAddRowHistory(sheet string, key string, entries []rowHistoryEntry, versions []blameVersion) string {
+	tableString := htmlAddSheetHeader("History of " + html.EscapeString(key) + " in " + html.EscapeString(sheet))
+
+	if len(entries) == 0 {
+		tableString += htmlAddSubHeading("Row not found in any version")
+		return tableString
+	}
+
+	tableString += htmlStartTable()
+	tableString += htmlAddTableHeaderDefaultDiff([]string{"Commit", "Date", "Author", "Summary", "Change", "Column", "Value"})
+
+	for _, entry := range entries {
+		commit := versions[entry.version].commit
+
+		for index, cell := range entry.cells {
+			commitCells := []string{"", "", "", "", ""}
+			if index == 0 {
+				commitCells = []string{"<code>" + commit.shortHash() + "</code>", commit.date, html.EscapeString(commit.author),
+					html.EscapeString(commit.summary), "<b>" + rowChangeLabel(entry.changeType) + "</b>"}
+				if entry.reason != "" {
+					commitCells[4] += "<br>" + html.EscapeString(entry.reason) + ", using the first row with the key"
+				}
+			}
+
+			value := ""
+			switch entry.changeType {
+			case addition:
+				value = html.EscapeString(cell.mine)
+			case deletion:
+				value = html.EscapeString(cell.theirs)
+			default:
+				value = htmlAddChangedValue(cell.theirs, cell.mine)
+			}
+
+			tableString += htmlAddRow(append(commitCells, html.EscapeString(cell.column), value))
+		}
+	}
+
+	tableString += htmlEndTable()
+	tableString += htmlAddBreakLine()
+
+	return tableString
+}
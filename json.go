@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// version of the json diff schema, bumped when fields change meaning or are removed
+const jsonSchemaVersion = 1
+
+type jsonDiff struct {
+	SchemaVersion      int                     `json:"schemaVersion"`
+	Theirs             string                  `json:"theirs"`
+	Mine               string                  `json:"mine"`
+	Rename             *jsonRename             `json:"rename,omitempty"`
+	Counts             jsonCounts              `json:"counts"`
+	WorkbookChanges    []jsonSheetChange       `json:"workbookChanges"`
+	DefinedNameChanges []jsonDefinedNameChange `json:"definedNameChanges"`
+	Sheets             []jsonSheet             `json:"sheets"`
+}
+
+type jsonRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type jsonCounts struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Changed int `json:"changed"`
+	Other   int `json:"other"`
+}
+
+type jsonSheetChange struct {
+	Change      string `json:"change"`
+	TheirsSheet string `json:"theirsSheet,omitempty"`
+	MineSheet   string `json:"mineSheet,omitempty"`
+	TheirsPos   int    `json:"theirsPosition,omitempty"`
+	MinePos     int    `json:"minePosition,omitempty"`
+	TheirsState string `json:"theirsState,omitempty"`
+	MineState   string `json:"mineState,omitempty"`
+}
+
+type jsonDefinedNameChange struct {
+	Change      string `json:"change"`
+	Name        string `json:"name"`
+	Scope       string `json:"scope,omitempty"`
+	TheirsRefer string `json:"theirsRefersTo,omitempty"`
+	MineRefer   string `json:"mineRefersTo,omitempty"`
+}
+
+type jsonSheet struct {
+	Name           string             `json:"name"`
+	TheirsSheet    string             `json:"theirsSheet,omitempty"`
+	MineSheet      string             `json:"mineSheet,omitempty"`
+	TheirsRegion   *string            `json:"theirsRegion"`
+	MineRegion     *string            `json:"mineRegion"`
+	KeyColumns     []string           `json:"keyColumns"`
+	SmartCompare   bool               `json:"smartCompare"`
+	FallbackReason string             `json:"fallbackReason,omitempty"`
+	Counts         jsonCounts         `json:"counts"`
+	ColumnChanges  []jsonColumnChange `json:"columnChanges"`
+	CommentChanges []jsonComment      `json:"commentChanges"`
+	RuleChanges    []jsonRuleChange   `json:"ruleChanges"`
+	RowChanges     []jsonRowChange    `json:"rowChanges"`
+}
+
+type jsonColumnChange struct {
+	Change       string `json:"change"`
+	Name         string `json:"name"`
+	OldName      string `json:"oldName,omitempty"`
+	TheirsColumn string `json:"theirsColumn,omitempty"`
+	MineColumn   string `json:"mineColumn,omitempty"`
+}
+
+type jsonComment struct {
+	Change     string `json:"change"`
+	Cell       string `json:"cell"`
+	TheirsCell string `json:"theirsCell,omitempty"`
+	MineCell   string `json:"mineCell,omitempty"`
+	Old        string `json:"old,omitempty"`
+	New        string `json:"new,omitempty"`
+}
+
+type jsonRuleChange struct {
+	Change       string `json:"change"`
+	Kind         string `json:"kind"`
+	TheirsRanges string `json:"theirsRanges,omitempty"`
+	MineRanges   string `json:"mineRanges,omitempty"`
+	Old          string `json:"old,omitempty"`
+	New          string `json:"new,omitempty"`
+}
+
+type jsonRowChange struct {
+	Change    string            `json:"change"`
+	Key       map[string]string `json:"key,omitempty"`
+	TheirsRow int               `json:"theirsRow,omitempty"`
+	MineRow   int               `json:"mineRow,omitempty"`
+	Cells     []jsonCellChange  `json:"cells"`
+}
+
+type jsonCellChange struct {
+	Column     string `json:"column"`
+	TheirsCell string `json:"theirsCell,omitempty"`
+	MineCell   string `json:"mineCell,omitempty"`
+	Old        string `json:"old"`
+	New        string `json:"new"`
+	OldFormula string `json:"oldFormula,omitempty"`
+	NewFormula string `json:"newFormula,omitempty"`
+	OldStyle   string `json:"oldStyle,omitempty"`
+	NewStyle   string `json:"newStyle,omitempty"`
+}
+
+// renders the diff as indented json. The schema is described in the README
+func jsonWorkbookDiff(diff workbookDiff) []byte {
+	output := jsonDiff{
+		SchemaVersion:      jsonSchemaVersion,
+		Theirs:             diff.header.theirs,
+		Mine:               diff.header.mine,
+		Counts:             jsonDiffCounts(diff.counts),
+		WorkbookChanges:    []jsonSheetChange{},
+		DefinedNameChanges: []jsonDefinedNameChange{},
+		Sheets:             []jsonSheet{},
+	}
+
+	if diff.header.rename.from != "" {
+		output.Rename = &jsonRename{From: diff.header.rename.from, To: diff.header.rename.to}
+	}
+
+	for _, change := range diff.sheetChanges {
+		output.WorkbookChanges = append(output.WorkbookChanges, jsonSheetChange{
+			Change:      jsonSheetChangeType(change.changeType),
+			TheirsSheet: change.theirsName,
+			MineSheet:   change.mineName,
+			TheirsPos:   change.theirsPos + 1,
+			MinePos:     change.minePos + 1,
+			TheirsState: change.theirsState,
+			MineState:   change.mineState,
+		})
+	}
+
+	for _, change := range diff.definedNameChanges {
+		name := definedNameChangeName(change)
+		output.DefinedNameChanges = append(output.DefinedNameChanges, jsonDefinedNameChange{
+			Change:      changeTypeString(change.changeType),
+			Name:        name.Name,
+			Scope:       name.Scope,
+			TheirsRefer: change.theirs.RefersTo,
+			MineRefer:   change.mine.RefersTo,
+		})
+	}
+
+	for _, sheet := range diff.sheets {
+		output.Sheets = append(output.Sheets, jsonSheetDiff(sheet))
+	}
+
+	jsonData, err := json.MarshalIndent(output, "", "    ")
+	if err != nil {
+		panic(err)
+	}
+
+	return append(jsonData, '\n')
+}
+
+func jsonSheetDiff(diff sheetDiff) jsonSheet {
+	sheet := jsonSheet{
+		Name:           diff.name,
+		TheirsSheet:    diff.theirsSheet,
+		MineSheet:      diff.mineSheet,
+		KeyColumns:     diff.primaryKeys,
+		SmartCompare:   diff.smartCompare,
+		FallbackReason: diff.fallbackReason,
+		Counts:         jsonDiffCounts(diff.counts),
+		ColumnChanges:  []jsonColumnChange{},
+		CommentChanges: []jsonComment{},
+		RuleChanges:    []jsonRuleChange{},
+		RowChanges:     []jsonRowChange{},
+	}
+
+	// the region of the side the sheet is not in is null
+	if diff.theirsSheet != "" {
+		region := regionString(diff.theirsRegion)
+		sheet.TheirsRegion = &region
+	}
+	if diff.mineSheet != "" {
+		region := regionString(diff.mineRegion)
+		sheet.MineRegion = &region
+	}
+
+	if sheet.KeyColumns == nil {
+		sheet.KeyColumns = []string{}
+	}
+
+	for _, change := range diff.columns.changes {
+		sheet.ColumnChanges = append(sheet.ColumnChanges, jsonColumnChange{
+			Change:       jsonColumnChangeType(change.changeType),
+			Name:         change.name,
+			OldName:      change.oldName,
+			TheirsColumn: columnLetter(change.theirsCol),
+			MineColumn:   columnLetter(change.mineCol),
+		})
+	}
+
+	for _, change := range diff.commentChanges {
+		sheet.CommentChanges = append(sheet.CommentChanges, jsonComment{
+			Change:     changeTypeString(change.changeType),
+			Cell:       change.cell,
+			TheirsCell: change.theirsCell,
+			MineCell:   change.mineCell,
+			Old:        commentString(change.theirs),
+			New:        commentString(change.mine),
+		})
+	}
+
+	for _, change := range diff.ruleChanges {
+		sheet.RuleChanges = append(sheet.RuleChanges, jsonRuleChange{
+			Change:       changeTypeString(change.changeType),
+			Kind:         change.kind,
+			TheirsRanges: change.theirsRanges,
+			MineRanges:   change.mineRanges,
+			Old:          change.theirs,
+			New:          change.mine,
+		})
+	}
+
+	for _, line := range diff.allLines() {
+		row := jsonRowChange{
+			Change:    changeTypeString(line.lineType),
+			TheirsRow: line.theirsRow,
+			MineRow:   line.mineRow,
+			Cells:     []jsonCellChange{},
+		}
+
+		if key := diff.lineKey(line); key != nil {
+			row.Key = make(map[string]string)
+			for _, value := range key {
+				row.Key[value.column] = value.value
+			}
+		}
+
+		for _, cell := range diff.cellChanges(line) {
+			row.Cells = append(row.Cells, jsonCellChange{
+				Column:     cell.column,
+				TheirsCell: cell.theirsCell,
+				MineCell:   cell.mineCell,
+				Old:        cell.theirs,
+				New:        cell.mine,
+				OldFormula: cell.theirsFormula,
+				NewFormula: cell.mineFormula,
+				OldStyle:   cell.theirsStyle,
+				NewStyle:   cell.mineStyle,
+			})
+		}
+
+		sheet.RowChanges = append(sheet.RowChanges, row)
+	}
+
+	return sheet
+}
+
+func jsonDiffCounts(counts diffCounts) jsonCounts {
+	return jsonCounts{Added: counts.added, Removed: counts.removed, Changed: counts.changed, Other: counts.other}
+}
+
+func jsonSheetChangeType(changeType sheetChangeType) string {
+	switch changeType {
+	case sheetAdded:
+		return "added"
+	case sheetRemoved:
+		return "removed"
+	case sheetRenamed:
+		return "renamed"
+	case sheetMoved:
+		return "moved"
+	default:
+		return "visibility"
+	}
+}
+
+func jsonColumnChangeType(changeType columnChangeType) string {
+	switch changeType {
+	case columnAdded:
+		return "added"
+	case columnRemoved:
+		return "removed"
+	case columnMoved:
+		return "moved"
+	default:
+		return "renamed"
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+
+// pointer files are small text files, anything bigger is the real workbook
+const lfsPointerMaxSize = 1024
+
+// a sha256 oid. The oid is used in the path of the object in the lfs store so
+// anything else is rejected
+var lfsOidPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// reads the oid of the object and the pointer from a git lfs pointer file.
+// The oid is blank if the file is not a pointer
+func readLFSPointer(path string) (string, []byte, error) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > lfsPointerMaxSize {
+		return "", nil, err
+	}
+
+	pointer, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !bytes.HasPrefix(pointer, []byte(lfsPointerVersion)) {
+		return "", nil, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(pointer))
+	for scanner.Scan() {
+		if oid, found := strings.CutPrefix(scanner.Text(), "oid sha256:"); found {
+			return strings.TrimSpace(oid), pointer, nil
+		}
+	}
+
+	return "", nil, errors.New("invalid git lfs pointer " + path)
+}
+
+// replaces the git lfs pointer at path with the workbook it points to. The
+// object is copied from the local lfs store or read with git lfs smudge
+func resolveLFSPointer(dir string, path string) error {
+	oid, pointer, err := readLFSPointer(path)
+	if err != nil || oid == "" {
+		return err
+	}
+
+	if !lfsOidPattern.MatchString(oid) {
+		return errors.New("invalid git lfs oid " + oid)
+	}
+
+	if objectPath, err := lfsObjectPath(dir, oid); err == nil {
+		if object, err := os.Open(objectPath); err == nil {
+			defer object.Close()
+			return copyToFile(object, path)
+		}
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("git", "lfs", "smudge")
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(pointer)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return errors.New("the workbook is stored in git lfs and object " + oid + " could not be read: " + message)
+	}
+
+	// git lfs smudge gives back the pointer when GIT_LFS_SKIP_SMUDGE is set
+	if bytes.HasPrefix(stdout.Bytes(), []byte(lfsPointerVersion)) {
+		return errors.New("the workbook is stored in git lfs and object " + oid + " is not available locally. Unset GIT_LFS_SKIP_SMUDGE or run git lfs fetch to download it and try again")
+	}
+
+	return copyToFile(&stdout, path)
+}
+
+// copies the git lfs pointer at path to a temp file and replaces the copy with
+// the workbook it points to. Returns the path of the copy
+func resolveLFSPointerCopy(dir string, path string) (string, error) {
+	pointer, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	tempFile, err := os.CreateTemp("", "ged-lfs-*"+filepath.Ext(path))
+	if err != nil {
+		return "", err
+	}
+	tempPath := tempFile.Name()
+
+	_, err = tempFile.Write(pointer)
+	tempFile.Close()
+	if err == nil {
+		err = resolveLFSPointer(dir, tempPath)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+
+	return tempPath, nil
+}
+
+// the path of an object in the lfs store of the repo, .git/lfs/objects/<ab>/<cd>/<oid>
+func lfsObjectPath(dir string, oid string) (string, error) {
+	gitDir, err := runGit(dir, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+
+	gitDir = filepath.FromSlash(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+
+	return filepath.Join(gitDir, "lfs", "objects", oid[0:2], oid[2:4], oid), nil
+}
+
+func copyToFile(source io.Reader, path string) error {
+	destFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(destFile, source); err != nil {
+		destFile.Close()
+		return err
+	}
+
+	return destFile.Close()
+}
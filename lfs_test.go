@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const testOid = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+
+func lfsPointer(oid string) string {
+	return lfsPointerVersion + "\noid sha256:" + oid + "\nsize 12\n"
+}
+
+// puts a git-lfs command that runs the script first on the path
+func fakeGitLFS(t *testing.T, script string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake git-lfs is a shell script")
+	}
+
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "git-lfs"), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestReadLFSPointer(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		oid     string
+		err     bool
+	}{
+		{name: "pointer", content: lfsPointer(testOid), oid: testOid},
+		{name: "workbook", content: "PK\x03\x04 not a pointer"},
+		{name: "pointer without an oid", content: lfsPointerVersion + "\nsize 12\n", err: true},
+		{name: "large file", content: lfsPointerVersion + strings.Repeat(" ", lfsPointerMaxSize)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.name)
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			oid, _, err := readLFSPointer(path)
+			if test.err != (err != nil) {
+				t.Fatalf("error %v, want error %v", err, test.err)
+			}
+			if oid != test.oid {
+				t.Errorf("oid %q, want %q", oid, test.oid)
+			}
+		})
+	}
+}
+
+func TestResolveLFSPointer(t *testing.T) {
+	dir := newTestRepo(t)
+
+	objectDir := filepath.Join(dir, ".git", "lfs", "objects", testOid[0:2], testOid[2:4])
+	if err := os.MkdirAll(objectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(objectDir, testOid), []byte("the workbook"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	missingOid := strings.Repeat("0", 64)
+
+	tests := []struct {
+		name    string
+		oid     string
+		script  string
+		content string
+		err     string
+	}{
+		{name: "object in the local store", oid: testOid, content: "the workbook"},
+		{name: "object from smudge", oid: missingOid, script: "cat > /dev/null; printf 'smudged workbook'", content: "smudged workbook"},
+		{name: "path in the oid", oid: "../../../../../../etc/passwd", err: "invalid git lfs oid"},
+		{name: "short oid", oid: "abcd", err: "invalid git lfs oid"},
+		{name: "smudge fails", oid: missingOid, script: "echo 'Object does not exist on the server' >&2; exit 2", err: "Object does not exist on the server"},
+		{name: "smudge skipped", oid: missingOid, script: "cat", err: "GIT_LFS_SKIP_SMUDGE"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeGitLFS(t, test.script)
+
+			path := filepath.Join(t.TempDir(), "book.xlsx")
+			if err := os.WriteFile(path, []byte(lfsPointer(test.oid)), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := resolveLFSPointer(dir, path)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error %v, want an error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != test.content {
+				t.Errorf("content %q, want %q", content, test.content)
+			}
+		})
+	}
+}
@@ -1,8 +1,7 @@
 package main
 
 import (
-	"bytes"
-	"encoding/csv"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -16,16 +15,59 @@ import (
 const VERSION = "0.2.4"
 
 var verboseOutput bool
+
+// diffs printed to stdout are written here, os.Stdout is pointed at stderr so
+// the progress messages don't end up in the diff
+var diffStdout = os.Stdout
 var sheetsMine []string
 var sheetsTheirs []string
 
 func usageMessage() {
 	fmt.Printf("Usage: ged [arguments] <excel workbook>\n")
+	fmt.Printf("       ged [arguments] <theirs workbook> <mine workbook>\n")
+	fmt.Printf("       ged -from <ref> [-to <ref>] <excel workbook>\n")
+	fmt.Printf("       ged -from <ref>..<ref> <excel workbook>\n")
+	fmt.Printf("       ged textconv [-f] <excel workbook>\n")
+	fmt.Printf("       ged range [arguments] <rev range>\n")
+	fmt.Printf("       ged blame [arguments] <excel workbook> [sheet] [range]\n")
+	fmt.Printf("       ged history [arguments] <excel workbook> -sheet <sheet> -key <column>=<value>\n")
+	fmt.Printf("       ged check [--staged] [excel workbooks]\n")
+	fmt.Printf("       ged hook install [-f]\n")
+	fmt.Printf("       ged merge [-k key] <base workbook> <ours workbook> <theirs workbook>\n")
 	flag.PrintDefaults()
 }
 
 // allow user to define .xlsx sheet, theirs version and primary key
 func main() {
+	// sub commands write to stdout for git so they run before the config is read
+	if len(os.Args) > 1 && os.Args[1] == "textconv" {
+		runTextconv(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "range" {
+		runRange(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "blame" {
+		runBlame(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		runHistory(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		runCheck(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "hook" {
+		runHook(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		os.Exit(runMerge(os.Args[2:]))
+	}
+
 	userConfig := getConfig()
 	var commitFlag = flag.String("c", userConfig.DefaultCommit, "Commit to compare against")
 	var keyFlag = flag.String("k", "", "The primary key to be used for diffing the excel sheet")
@@ -36,6 +78,20 @@ func main() {
 	var verboseFlag = flag.Bool("v", false, "Display verbose output")
 	var aboutFlag = flag.Bool("about", false, "Display about page for ged")
 	var newDefaultCommit = flag.String("setDefaultCommit", "", "Sets the default commit")
+	var formulaFlag = flag.Bool("f", false, "Compare cell formulas as well as their values")
+	var styleFlag = flag.Bool("s", false, "Compare cell styles (font, fill, border, alignment and number format) as well as their values")
+	var setupDifftoolFlag = flag.Bool("setupDifftool", false, "Registers ged as a git difftool so it can be used with git difftool -t ged")
+	var setupTextconvFlag = flag.Bool("setupTextconv", false, "Registers ged as the git diff driver for files marked diff=ged in .gitattributes")
+	var setupMergeFlag = flag.Bool("setupMerge", false, "Registers ged as the git merge driver for files marked merge=ged in .gitattributes")
+	var fromFlag = flag.String("from", "", "Commit to use for theirs instead of -c. Can be a range A..B or A...B, which compares B against A or against the merge base of A and B")
+	var toFlag = flag.String("to", "", "Commit to use for mine instead of the working tree file")
+	var indexFlag = flag.Bool("index", false, "Compare the working tree file against the version in the index (staged)")
+	var stagedFlag = flag.Bool("staged", false, "Compare the version in the index (staged) against HEAD, or against -from if it is given")
+	var stashFlag = flag.String("stash", "", "Compare the working tree file against a stash entry, e.g. 0 for stash@{0}")
+	var formatFlag = flag.String("format", "html", "Format of the diff: html, json, text, markdown or xlsx. Text is printed to stdout")
+	var contextFlag = flag.Int("context", defaultContextRows, "Number of unchanged rows shown around each change with -format text")
+	var sheetRegions = regionFlags{}
+	flag.Var(sheetRegions, "region", "Header row and data range of a sheet as <sheet>!<range>, e.g. Sheet1!B5:F100 or Sheet1!5. Use * as the sheet name for all sheets. Can be repeated. Default is to detect the header row and data range")
 
 	flag.Usage = usageMessage
 	flag.Parse()
@@ -54,20 +110,90 @@ func main() {
 		os.Exit(0)
 	}
 
-	if len(flag.Args()) != 1 {
+	if *setupDifftoolFlag {
+		if err := setupDifftool(); err != nil {
+			fmt.Printf("Error: Unable to write git config: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("ged registered as a git difftool. Use: git difftool -t ged <excel workbook>\n")
+		os.Exit(0)
+	}
+
+	if *setupTextconvFlag {
+		if err := setupTextconv(); err != nil {
+			fmt.Printf("Error: Unable to write git config: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("ged registered as a git diff driver. Add this line to .gitattributes: *.xlsx diff=ged\n")
+		os.Exit(0)
+	}
+
+	if *setupMergeFlag {
+		if err := setupMerge(); err != nil {
+			fmt.Printf("Error: Unable to write git config: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("ged registered as a git merge driver. Add this line to .gitattributes: *.xlsx merge=ged\n")
+		os.Exit(0)
+	}
+
+	// two workbooks are compared directly, this is how git difftool calls ged
+	if len(flag.Args()) == 2 {
+		if *localCompareFlag != "" {
+			fmt.Printf("Error: -lc can not be used when two workbooks are given\n")
+			usageMessage()
+			os.Exit(1)
+		}
+		*localCompareFlag = flag.Arg(0)
+	} else if len(flag.Args()) != 1 {
 		fmt.Printf("Error: Incorrect number of positional arguments.\n")
 		usageMessage()
 		os.Exit(0)
 	}
 
+	if *localCompareFlag != "" && (*fromFlag != "" || *toFlag != "" || *indexFlag || *stagedFlag || *stashFlag != "") {
+		fmt.Printf("Error: -from, -to, -index, -staged and -stash can not be used when comparing two local workbooks\n")
+		os.Exit(0)
+	}
+
+	if (*indexFlag || *stashFlag != "") && (*fromFlag != "" || *toFlag != "") || (*stagedFlag && *toFlag != "") ||
+		(*indexFlag && *stagedFlag) || (*stashFlag != "" && (*indexFlag || *stagedFlag)) {
+		fmt.Printf("Error: -index, -staged and -stash can not be used together or with -from and -to (-staged can be used with -from)\n")
+		os.Exit(0)
+	}
+
+	if _, ok := outputFormats[*formatFlag]; !ok {
+		fmt.Printf("Error: Unknown format %s, expected html, json, text, markdown or xlsx\n", *formatFlag)
+		os.Exit(1)
+	}
+
+	if *contextFlag < 0 {
+		fmt.Printf("Error: -context can not be negative\n")
+		os.Exit(1)
+	}
+
+	if outputFormats[*formatFlag] == "" {
+		os.Stdout = os.Stderr
+	}
+
 	if verboseOutput {
 		fmt.Printf("CommitFlag: %s\r\n", *commitFlag)
+		fmt.Printf("FromFlag: %s\r\n", *fromFlag)
+		fmt.Printf("ToFlag: %s\r\n", *toFlag)
+		fmt.Printf("IndexFlag: %t\r\n", *indexFlag)
+		fmt.Printf("StagedFlag: %t\r\n", *stagedFlag)
+		fmt.Printf("StashFlag: %s\r\n", *stashFlag)
 		fmt.Printf("Key: %s\r\n", *keyFlag)
 		fmt.Printf("otherFileNameFlag: %s\r\n", *otherFileNameFlag)
 		fmt.Printf("outputFlag: %s\r\n", *outputFlag)
 		fmt.Printf("localCompareFlag: %s\r\n", *localCompareFlag)
 		fmt.Printf("SmartCompareOffFlag: %t\r\n", *smartCompareOffFlag)
 		fmt.Printf("VerboseFlag: %t\r\n", *verboseFlag)
+		fmt.Printf("FormulaFlag: %t\r\n", *formulaFlag)
+		fmt.Printf("StyleFlag: %t\r\n", *styleFlag)
+		fmt.Printf("Regions: %s\r\n", sheetRegions)
+		fmt.Printf("Format: %s\r\n", *formatFlag)
+		fmt.Printf("Context: %d\r\n", *contextFlag)
 	}
 
 	currentDir, err := os.Getwd()
@@ -94,9 +220,9 @@ func main() {
 		gitRootString = gitRootString + string(filepath.Separator)
 	}
 
-	workBookGivenPath := flag.Arg(0)
-	workBookFullPath := filepath.Join(currentDir, workBookGivenPath)
-	mineWorkBookName := strings.ReplaceAll(filepath.Base(workBookFullPath), ".xlsx", "")
+	workBookGivenPath := flag.Arg(len(flag.Args()) - 1)
+	workBookFullPath := absolutePath(currentDir, workBookGivenPath)
+	mineWorkBookName := workbookName(workBookFullPath)
 
 	var workBookGitPath = ""
 	var theirWorkBookName = ""
@@ -108,24 +234,64 @@ func main() {
 	} else if *localCompareFlag == "" { // use provided path
 		workBookGitPath = *otherFileNameFlag
 	} else {
-		theirWorkBook = filepath.Join(currentDir, filepath.FromSlash(*localCompareFlag))
+		theirWorkBook = absolutePath(currentDir, *localCompareFlag)
+	}
+
+	commit := *commitFlag
+	toCommit := *toFlag
+	if *fromFlag != "" {
+		commit = *fromFlag
 	}
 
+	switch {
+	case *indexFlag:
+		commit = indexRef
+	case *stagedFlag:
+		if *fromFlag == "" {
+			commit = "HEAD"
+		}
+		toCommit = indexRef
+	case *stashFlag != "":
+		commit = *stashFlag
+		if !strings.HasPrefix(commit, "stash@{") {
+			commit = "stash@{" + commit + "}"
+		}
+	}
+
+	mineCommit := ""
 	if *localCompareFlag == "" {
-		theirWorkBookName = strings.ReplaceAll(filepath.Base(workBookGitPath), ".xlsx", "")
+		commit, mineCommit, err = resolveRefRange(gitRootString, commit, toCommit)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(0)
+		}
+	}
 
-		theirWorkBook = filepath.Join(currentDir, theirWorkBookName+"-Theirs.xlsx")
+	// follow the workbook if it was renamed or moved since the theirs commit
+	renamedFrom := ""
+	if *otherFileNameFlag == "" && *localCompareFlag == "" {
+		renamedFrom = findRenamedPath(gitRootString, commit, mineCommit, workBookGitPath)
+		if renamedFrom != "" {
+			workBookGitPath = filepath.FromSlash(renamedFrom)
+		}
+	}
+
+	if *localCompareFlag == "" {
+		theirWorkBookName = workbookName(workBookGitPath)
 	} else {
-		theirWorkBookName = strings.ReplaceAll(filepath.Base(theirWorkBook), ".xlsx", "")
+		theirWorkBookName = workbookName(theirWorkBook)
 	}
 
 	var outputFilePath = ""
 
-	if *outputFlag == "" {
-		outputFilePath = filepath.Join(currentDir, mineWorkBookName+"-diff.html")
-	} else {
-		outputDir := filepath.FromSlash(*outputFlag)
-		outputFilePath = filepath.Join(currentDir, outputDir, mineWorkBookName+"-diff.html")
+	// formats without an extension are printed to stdout
+	if outputExt := outputFormats[*formatFlag]; outputExt != "" {
+		if *outputFlag == "" {
+			outputFilePath = filepath.Join(currentDir, mineWorkBookName+"-diff"+outputExt)
+		} else {
+			outputDir := filepath.FromSlash(*outputFlag)
+			outputFilePath = filepath.Join(currentDir, outputDir, mineWorkBookName+"-diff"+outputExt)
+		}
 	}
 
 	if verboseOutput {
@@ -133,19 +299,28 @@ func main() {
 		fmt.Printf("workBookGitPath: %s\n", workBookGitPath)
 		fmt.Printf("MineWorkBookName: %s\n", mineWorkBookName)
 		fmt.Printf("TheirWorkBookName: %s\n", theirWorkBookName)
-		fmt.Printf("TheirWorkBook: %s\n", theirWorkBook)
 		fmt.Printf("workBookFullPath: %s\n", workBookFullPath)
 		fmt.Printf("outputFilePath: %s\n", outputFilePath)
 	}
 
-	commit := *commitFlag
-
+	var header diffHeader
 	if *localCompareFlag == "" {
-		fmt.Printf("Diffing %s against their %s at %s\r\n", mineWorkBookName, theirWorkBookName, commit)
+		header.theirs = filepath.ToSlash(workBookGitPath) + " in " + refLabel(gitRootString, commit)
+		header.mine = filepath.ToSlash(strings.ReplaceAll(workBookFullPath, gitRootString, "")) + " in " + refLabel(gitRootString, mineCommit)
+
+		fmt.Printf("Diffing %s in %s against their %s in %s\r\n", mineWorkBookName, refLabel(gitRootString, mineCommit), theirWorkBookName, refLabel(gitRootString, commit))
 	} else {
+		header.theirs = filepath.ToSlash(*localCompareFlag)
+		header.mine = filepath.ToSlash(workBookGivenPath)
+
 		fmt.Printf("Diffing %s against their local %s\r\n", mineWorkBookName, theirWorkBookName)
 	}
 
+	if renamedFrom != "" {
+		header.rename = fileRename{from: renamedFrom, to: filepath.ToSlash(strings.ReplaceAll(workBookFullPath, gitRootString, ""))}
+		fmt.Printf("%s was renamed from %s\r\n", header.rename.to, header.rename.from)
+	}
+
 	primaryKey := *keyFlag
 	primaryKeyList := []string{}
 	if primaryKey != "" {
@@ -159,117 +334,101 @@ func main() {
 		os.Exit(0)
 	}
 
+	// regions for workbooks in a repository can be set in the region config
+	regions := sheetRegions
 	if *localCompareFlag == "" {
-		theirWorkbookFile, err := os.Create(theirWorkBook)
-		if err != nil {
-			panic(err)
-		}
-
-		workBookGitPath = strings.ReplaceAll(workBookGitPath, "\\", "/")
-
-		//get workbook theirs
-		commandString := commit + ":" + workBookGitPath
-
-		var stderr bytes.Buffer
-
-		cmd := exec.Command("git", "show", commandString)
-		cmd.Dir = gitRootString
-		cmd.Stdout = theirWorkbookFile
-		cmd.Stderr = &stderr
-
-		if err := cmd.Run(); err != nil {
-			fmt.Println(stderr.String())
-			theirWorkbookFile.Close()
+		regions = workbookRegionsFor(gitRootString, strings.ReplaceAll(workBookFullPath, gitRootString, ""), sheetRegions)
+	}
 
-			os.Remove(theirWorkBook)
-			panic(err)
+	// the workbooks read from git are written to temp files that are removed
+	// before ged exits
+	var tempWorkbooks []string
+	exit := func(code int) {
+		for _, tempWorkbook := range tempWorkbooks {
+			os.Remove(tempWorkbook)
 		}
-
-		theirWorkbookFile.Close()
+		os.Exit(code)
 	}
 
-	excelMine, err := excelize.OpenFile(workBookFullPath)
-	if err != nil {
-		panic(err)
+	if *localCompareFlag == "" {
+		//get workbook theirs
+		theirWorkBook, err = writeTempGitBlob(gitRootString, commit, workBookGitPath, theirWorkBookName+"-Theirs-*"+filepath.Ext(workBookGitPath))
+		if err != nil {
+			fmt.Printf("Error: Unable to read %s in %s: %s\n", filepath.ToSlash(workBookGitPath), refLabel(gitRootString, commit), err)
+			exit(1)
+		}
+		tempWorkbooks = append(tempWorkbooks, theirWorkBook)
 	}
 
-	defer func() {
-		//close the spreadsheet
-		if err := excelMine.Close(); err != nil {
-			panic(err)
+	// mine is read from a commit so the working tree is not used
+	mineWorkBook := workBookFullPath
+	if mineCommit != "" {
+		mineGitPath := strings.ReplaceAll(workBookFullPath, gitRootString, "")
+		mineWorkBook, err = writeTempGitBlob(gitRootString, mineCommit, mineGitPath, mineWorkBookName+"-Mine-*"+filepath.Ext(workBookFullPath))
+		if err != nil {
+			fmt.Printf("Error: Unable to read %s in %s: %s\n", filepath.ToSlash(mineGitPath), refLabel(gitRootString, mineCommit), err)
+			exit(1)
 		}
-	}()
-
-	excelTheirs, err := excelize.OpenFile(theirWorkBook)
-
-	if err != nil {
-		panic(err)
+		tempWorkbooks = append(tempWorkbooks, mineWorkBook)
 	}
 
-	defer func() {
-		//close the spreadsheet
-		if err := excelTheirs.Close(); err != nil {
-			panic(err)
-		}
-	}()
+	options := diffOptions{primaryKeys: primaryKeyList, formulas: *formulaFlag, styles: *styleFlag, regions: regions, smartCompare: !*smartCompareOffFlag, format: *formatFlag, context: *contextFlag}
+	if _, err := diffWorkbooks(theirWorkBook, mineWorkBook, outputFilePath, header, options); err != nil {
+		fmt.Printf("Error: Unable to open workbook: %s\n", err)
+		exit(1)
+	}
 
-	// write 'mine' sheets to a csv
-	sheetsMine = writeSheetsToCsv(excelMine, true)
+	if outputFilePath != "" {
+		fmt.Printf("Diff written to %s\r\n", outputFilePath)
+	}
 
-	// write 'theirs' sheets to a csv
-	sheetsTheirs = writeSheetsToCsv(excelTheirs, false)
+	exit(0)
+}
 
-	htmlFile, err := os.Create(outputFilePath)
-	if err != nil {
-		removeFiles(sheetsMine, true)
-		removeFiles(sheetsTheirs, false)
-		panic(err)
+// paths given on the command line can be relative to the current directory or
+// absolute like the temp files git difftool passes in
+func absolutePath(currentDir string, path string) string {
+	if isNullDevice(path) {
+		return path
 	}
 
-	combinedSheets := concatSheetNames(sheetsTheirs, sheetsMine)
-
-	for _, sheetName := range combinedSheets {
-		mineFile, err := os.Open(getSheetFileName(sheetName, true))
-		if err != nil {
-			mineFile, err = os.Create(getSheetFileName(sheetName, true))
-			if err != nil {
-				panic(err)
-			}
-		}
-		csvReaderMine := csv.NewReader(mineFile)
-		dataMine, err := csvReaderMine.ReadAll()
-		if err != nil {
-			panic(err)
-		}
-		mineFile.Close()
+	path = filepath.FromSlash(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(currentDir, path)
+}
 
-		theirsFile, err := os.Open(getSheetFileName(sheetName, false))
-		if err != nil {
-			theirsFile, err = os.Create(getSheetFileName(sheetName, false))
-			if err != nil {
-				panic(err)
-			}
-		}
+// the file name of the workbook without its extension
+func workbookName(path string) string {
+	base := filepath.Base(filepath.FromSlash(path))
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
 
-		csvReaderTheirs := csv.NewReader(theirsFile)
-		dataTheirs, err := csvReaderTheirs.ReadAll()
-		if err != nil {
-			panic(err)
-		}
-		theirsFile.Close()
+// opens the workbook at path. git difftool passes the null device for a file
+// that does not exist on one side so that is opened as an empty workbook
+func openWorkbook(path string) (*excelize.File, error) {
+	if isNullDevice(path) {
+		return emptyWorkbook(), nil
+	}
 
-		compareCSV(dataTheirs, dataMine, primaryKeyList, sheetName, htmlFile, !*smartCompareOffFlag)
+	// the working tree has the pointer when git lfs is not installed or the file was not pulled
+	if oid, _, err := readLFSPointer(path); err == nil && oid != "" {
+		return nil, errors.New(path + " is a git lfs pointer, run git lfs pull to download the workbook")
 	}
 
-	htmlFile.Close()
+	return excelize.OpenFile(path)
+}
 
-	// remove temp csv files
-	removeFiles(combinedSheets, true)
-	removeFiles(combinedSheets, false)
-	if *localCompareFlag == "" {
-		err = os.Remove(theirWorkBook)
-		if err != nil {
-			panic(err)
-		}
-	}
+// a workbook without any sheets. A new file always has Sheet1, which would show
+// up as a removed or added sheet when a workbook is compared with the null device
+func emptyWorkbook() *excelize.File {
+	excelFile := excelize.NewFile()
+	excelFile.WorkBook.Sheets.Sheet = nil
+	excelFile.SheetCount = 0
+	return excelFile
+}
+
+func isNullDevice(path string) bool {
+	return path == os.DevNull || path == "/dev/null"
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// sheets with more changed rows than this only show the first rows so the
+// report still fits in a pull request description
+const markdownRowLimit = 50
+
+var markdownEscaper = strings.NewReplacer("\\", "\\\\", "|", "\\|", "*", "\\*", "_", "\\_", "~", "\\~", "`", "\\`",
+	"<", "&lt;", ">", "&gt;", "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// renders the diff as github flavored markdown with a summary table of the
+// changes in each sheet followed by a table of the changed rows of each sheet
+func markdownWorkbookDiff(diff workbookDiff) string {
+	var builder strings.Builder
+
+	builder.WriteString("## Workbook diff\n\n")
+	if diff.header.theirs != "" || diff.header.mine != "" {
+		builder.WriteString("**Theirs:** " + markdownEscape(diff.header.theirs) + "<br>\n")
+		builder.WriteString("**Mine:** " + markdownEscape(diff.header.mine) + "\n\n")
+	}
+	if diff.header.rename.from != "" {
+		builder.WriteString("Renamed from " + markdownEscape(diff.header.rename.from) + " to " + markdownEscape(diff.header.rename.to) + "\n\n")
+	}
+
+	builder.WriteString("| Sheet | Compared by | Added | Removed | Changed | Other |\n")
+	builder.WriteString("| --- | --- | ---: | ---: | ---: | ---: |\n")
+	for _, sheet := range diff.sheets {
+		builder.WriteString(markdownRow([]string{markdownEscape(sheet.name), markdownEscape(sheet.comparedBy()), strconv.Itoa(sheet.counts.added),
+			strconv.Itoa(sheet.counts.removed), strconv.Itoa(sheet.counts.changed), strconv.Itoa(sheet.counts.other)}))
+	}
+	builder.WriteString(markdownRow([]string{"**Total**", "", strconv.Itoa(diff.counts.added), strconv.Itoa(diff.counts.removed),
+		strconv.Itoa(diff.counts.changed), strconv.Itoa(diff.counts.other)}))
+	builder.WriteString("\n")
+
+	if len(diff.sheetChanges) > 0 {
+		builder.WriteString("### Workbook\n\n")
+		for _, change := range diff.sheetChanges {
+			builder.WriteString("- " + markdownEscape(textSheetChange(change)) + "\n")
+		}
+		builder.WriteString("\n")
+	}
+
+	if len(diff.definedNameChanges) > 0 {
+		builder.WriteString("### Defined names\n\n")
+		for _, change := range diff.definedNameChanges {
+			name := definedNameChangeName(change)
+			builder.WriteString("- " + definedNameChangeString(change) + ": " + markdownEscape(name.Name) + " " +
+				markdownEscape(textChangedValue(change.theirs.RefersTo, change.mine.RefersTo, change.changeType)) + "\n")
+		}
+		builder.WriteString("\n")
+	}
+
+	for _, sheet := range diff.sheets {
+		builder.WriteString(markdownSheetDiff(sheet))
+	}
+
+	return builder.String()
+}
+
+func markdownSheetDiff(diff sheetDiff) string {
+	var builder strings.Builder
+
+	builder.WriteString("### " + markdownEscape(diff.name) + "\n\n")
+
+	if diff.fallbackReason != "" {
+		builder.WriteString("Rows compared in order: " + markdownEscape(diff.fallbackReason) + "\n\n")
+	}
+
+	if !regionIsWholeSheet(diff.theirsRegion) || !regionIsWholeSheet(diff.mineRegion) {
+		builder.WriteString("Theirs " + regionString(diff.theirsRegion) + ", mine " + regionString(diff.mineRegion) + "\n\n")
+	}
+
+	changes := diff.otherChanges()
+	for _, change := range changes {
+		builder.WriteString("- " + markdownEscape(change) + "\n")
+	}
+	if len(changes) > 0 {
+		builder.WriteString("\n")
+	}
+
+	lines := diff.allLines()
+	if len(lines) == 0 {
+		builder.WriteString("_" + diff.equalMessage + "_\n\n")
+		return builder.String()
+	}
+
+	header := []string{"Change", "Theirs Row", "Mine Row"}
+	alignment := []string{"---", "---:", "---:"}
+	for _, name := range diff.header {
+		header = append(header, markdownEscape(name))
+		alignment = append(alignment, "---")
+	}
+	builder.WriteString(markdownRow(header))
+	builder.WriteString(markdownRow(alignment))
+
+	for index, line := range lines {
+		if index == markdownRowLimit {
+			builder.WriteString("\n_" + strconv.Itoa(len(lines)-markdownRowLimit) + " more rows_\n")
+			break
+		}
+		builder.WriteString(markdownDiffRow(diff, line))
+	}
+	builder.WriteString("\n")
+
+	return builder.String()
+}
+
+// added cells are bold, removed cells are struck through and changed cells
+// show both
+func markdownDiffRow(diff sheetDiff, line differenceLine) string {
+	row := []string{rowChangeLabel(line.lineType), markdownRowNumber(line.theirsRow), markdownRowNumber(line.mineRow)}
+
+	switch line.lineType {
+	case addition:
+		for index, value := range diff.dataMine[line.minePos] {
+			row = append(row, markdownMarkup(markdownCellValue(value, detailAt(diff.detailsMine.formulas, line.minePos, index)), "**"))
+		}
+	case deletion:
+		for index, value := range diff.dataTheirs[line.theirsPos] {
+			row = append(row, markdownMarkup(markdownCellValue(value, detailAt(diff.detailsTheirs.formulas, line.theirsPos, index)), "~~"))
+		}
+	default:
+		theirsRow := diff.dataTheirs[line.theirsPos]
+		mineRow := diff.dataMine[line.minePos]
+
+		for index := 0; index < max(len(theirsRow), len(mineRow)); index++ {
+			theirs := markdownCellValue(cellAt(theirsRow, index), detailAt(diff.detailsTheirs.formulas, line.theirsPos, index))
+			mine := markdownCellValue(cellAt(mineRow, index), detailAt(diff.detailsMine.formulas, line.minePos, index))
+
+			switch {
+			case cellContentDiffers(diff.dataTheirs, diff.dataMine, diff.detailsTheirs, diff.detailsMine, line.theirsPos, line.minePos, index):
+				row = append(row, strings.TrimSpace(markdownMarkup(theirs, "~~")+" "+markdownMarkup(mine, "**")))
+			case cellStyleDiffers(diff.detailsTheirs, diff.detailsMine, line.theirsPos, line.minePos, index):
+				_, mineStyle := styleDifference(detailAt(diff.detailsTheirs.styles, line.theirsPos, index), detailAt(diff.detailsMine.styles, line.minePos, index))
+				row = append(row, strings.TrimSpace(mine+" _"+markdownEscape(mineStyle)+"_"))
+			default:
+				row = append(row, mine)
+			}
+		}
+	}
+
+	return markdownRow(row)
+}
+
+func markdownRow(cells []string) string {
+	return "| " + strings.Join(cells, " | ") + " |\n"
+}
+
+func markdownEscape(value string) string {
+	return markdownEscaper.Replace(value)
+}
+
+// the escaped value of a cell followed by its formula if it has one
+func markdownCellValue(value string, formula string) string {
+	value = markdownEscape(value)
+	if formula != "" {
+		value += " `" + strings.NewReplacer("`", "'", "|", "\\|").Replace(formula) + "`"
+	}
+	return value
+}
+
+// wraps a value in bold or strikethrough markup, blank cells are left blank
+func markdownMarkup(value string, markup string) string {
+	if value == "" {
+		return ""
+	}
+	return markup + value + markup
+}
+
+func markdownRowNumber(row int) string {
+	if row == 0 {
+		return ""
+	}
+	return strconv.Itoa(row)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownSheetDiff(t *testing.T) {
+	diff := newTestSheetDiff("Data", "Data",
+		[][]string{{"ID", "Note"}, {"1", "a|b"}, {"2", "*x*"}, {"4", "same"}},
+		[][]string{{"ID", "Note"}, {"1", "a|c"}, {"3", "line1\nline2"}, {"4", "same"}}, []string{"ID"}, true)
+
+	want := "### Data\n\n" +
+		"| Change | Theirs Row | Mine Row | ID | Note |\n" +
+		"| --- | ---: | ---: | --- | --- |\n" +
+		"| Row changed | 2 | 2 | 1 | ~~a\\|b~~ **a\\|c** |\n" +
+		"| Row removed | 3 |  | ~~2~~ | ~~\\*x\\*~~ |\n" +
+		"| Row added |  | 3 | **3** | **line1<br>line2** |\n\n"
+
+	if got := markdownSheetDiff(diff); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMarkdownSheetDiffRowLimit(t *testing.T) {
+	dataTheirs := [][]string{{"ID", "Value"}}
+	dataMine := [][]string{{"ID", "Value"}}
+	for id := 1; id <= markdownRowLimit+10; id++ {
+		dataTheirs = append(dataTheirs, []string{strconv.Itoa(id), "old"})
+		dataMine = append(dataMine, []string{strconv.Itoa(id), "new"})
+	}
+
+	got := markdownSheetDiff(newTestSheetDiff("Data", "Data", dataTheirs, dataMine, []string{"ID"}, true))
+
+	if rows := strings.Count(got, "| Row changed |"); rows != markdownRowLimit {
+		t.Errorf("%d rows, want %d", rows, markdownRowLimit)
+	}
+	if !strings.Contains(got, "\n_10 more rows_\n") {
+		t.Errorf("missing the count of the rows left out:\n%s", got)
+	}
+	if !strings.Contains(got, "| Row changed | "+strconv.Itoa(markdownRowLimit+1)+" | ") || strings.Contains(got, "| "+strconv.Itoa(markdownRowLimit+2)+" | ") {
+		t.Errorf("the first %d rows should be listed:\n%s", markdownRowLimit, got)
+	}
+}
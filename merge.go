@@ -0,0 +1,677 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type mergeConflict struct {
+	sheet  string
+	cell   string // cell in the merged workbook, blank if the row is not in it
+	key    string
+	reason string
+	base   string
+	ours   string
+	theirs string
+}
+
+// the changes made to the ours sheet to merge in theirs. Rows are zero based
+// rows of the ours and theirs sheets
+type sheetMergePlan struct {
+	cellUpdates []cellUpdate
+	deletions   []int
+	insertions  []rowInsertion
+	conflicts   []mergeConflict
+	conflictAt  []int // ours row of each conflict, -1 if the row is not in ours
+}
+
+type cellUpdate struct {
+	oursRow   int
+	theirsRow int
+	col       int
+}
+
+type rowInsertion struct {
+	afterOursRow int
+	theirsRow    int
+}
+
+// the values, formulas and styles of a sheet read for merging
+type mergeSheetData struct {
+	values   [][]string
+	formulas [][]string
+	styles   [][]string
+}
+
+func mergeUsage() {
+	fmt.Printf("Usage: ged merge [arguments] <base workbook> <ours workbook> <theirs workbook>\n")
+	fmt.Printf("The merged workbook is written over the ours workbook\n")
+}
+
+// ged merge is a git merge driver. It merges the changes from base to theirs
+// into ours cell by cell and writes the result over ours. Returns the exit code
+// for git, 1 if there are conflicts and 2 if the merge failed
+func runMerge(args []string) int {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	var keyFlag = flags.String("k", "", "The primary key to be used for matching rows")
+	var verboseFlag = flags.Bool("v", false, "Display verbose output")
+	flags.Usage = func() {
+		mergeUsage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	verboseOutput = *verboseFlag
+
+	if flags.NArg() != 3 {
+		flags.Usage()
+		return 2
+	}
+
+	primaryKeyList := []string{}
+	if *keyFlag != "" {
+		primaryKeyList = append(primaryKeyList, *keyFlag)
+	}
+
+	excelBase, err := openWorkbook(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: Unable to open base workbook: %s\n", err)
+		return 2
+	}
+	defer excelBase.Close()
+
+	excelOurs, err := openWorkbook(flags.Arg(1))
+	if err != nil {
+		fmt.Printf("Error: Unable to open ours workbook: %s\n", err)
+		return 2
+	}
+	defer excelOurs.Close()
+
+	excelTheirs, err := openWorkbook(flags.Arg(2))
+	if err != nil {
+		fmt.Printf("Error: Unable to open theirs workbook: %s\n", err)
+		return 2
+	}
+	defer excelTheirs.Close()
+
+	conflicts := mergeWorkbooks(excelBase, excelOurs, excelTheirs, primaryKeyList)
+
+	if len(conflicts) > 0 {
+		addConflictSheet(excelOurs, conflicts)
+	}
+
+	// git gives the merge driver temp files without an extension so keep the
+	// content types of the ours workbook instead of guessing from the name
+	excelOurs.Path = ""
+
+	oursFile, err := os.Create(flags.Arg(1))
+	if err != nil {
+		fmt.Printf("Error: Unable to write merged workbook: %s\n", err)
+		return 2
+	}
+
+	if err := excelOurs.Write(oursFile); err != nil {
+		oursFile.Close()
+		fmt.Printf("Error: Unable to write merged workbook: %s\n", err)
+		return 2
+	}
+	oursFile.Close()
+
+	if len(conflicts) > 0 {
+		fmt.Printf("CONFLICT: %d cell conflicts while merging workbook. See the Conflicts sheet.\n", len(conflicts))
+		for _, conflict := range conflicts {
+			fmt.Printf("  %s\n", conflictString(conflict))
+		}
+		return 1
+	}
+
+	return 0
+}
+
+func conflictString(conflict mergeConflict) string {
+	location := conflict.sheet
+	if conflict.cell != "" {
+		location += "!" + conflict.cell
+	}
+	if conflict.key != "" {
+		location += " (" + conflict.key + ")"
+	}
+	return fmt.Sprintf("%s: %s. base: %q ours: %q theirs: %q", location, conflict.reason, conflict.base, conflict.ours, conflict.theirs)
+}
+
+func readMergeSheet(excelFile *excelize.File, sheet string) mergeSheetData {
+	rows, err := excelFile.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		panic(err)
+	}
+
+	return mergeSheetData{values: rows, formulas: readSheetFormulas(excelFile, sheet), styles: readMergeStyles(excelFile, sheet)}
+}
+
+// the style descriptions of the sheet with cells in the default style left
+// blank, so they are the same as cells past the end of a row
+func readMergeStyles(excelFile *excelize.File, sheet string) [][]string {
+	styles := readSheetStyles(excelFile, sheet)
+
+	defaultStyle, err := excelFile.GetStyle(0)
+	if err != nil {
+		panic(err)
+	}
+	defaultDescription := styleDescription(defaultStyle)
+
+	for _, row := range styles {
+		for col := range row {
+			if row[col] == defaultDescription {
+				row[col] = ""
+			}
+		}
+	}
+
+	return styles
+}
+
+// the value, formula and style of a cell as one string so they are all compared
+func mergeCellContent(data mergeSheetData, row int, col int) string {
+	return detailAt(data.values, row, col) + "%@!#!@%" + detailAt(data.formulas, row, col) + "%@!#!@%" + detailAt(data.styles, row, col)
+}
+
+// the text shown for a cell in the conflict report
+func mergeCellText(data mergeSheetData, row int, col int) string {
+	if formula := detailAt(data.formulas, row, col); formula != "" {
+		return formula
+	}
+	return detailAt(data.values, row, col)
+}
+
+func mergeSheetWidth(sheets ...mergeSheetData) int {
+	width := 0
+	for _, sheet := range sheets {
+		for _, row := range sheet.values {
+			width = max(width, len(row))
+		}
+		for _, row := range sheet.formulas {
+			width = max(width, len(row))
+		}
+		for _, row := range sheet.styles {
+			width = max(width, len(row))
+		}
+	}
+	return width
+}
+
+func mergeRowContent(data mergeSheetData, row int, width int) string {
+	var cells []string
+	for col := 0; col < width; col++ {
+		cells = append(cells, mergeCellContent(data, row, col))
+	}
+	return rowToString(cells)
+}
+
+func mergeSheetsEqual(first, second mergeSheetData) bool {
+	width := mergeSheetWidth(first, second)
+	for row := 0; row < max(len(first.values), len(second.values)); row++ {
+		if mergeRowContent(first, row, width) != mergeRowContent(second, row, width) {
+			return false
+		}
+	}
+	return true
+}
+
+// merges every sheet of theirs into ours and returns the conflicts
+func mergeWorkbooks(excelBase, excelOurs, excelTheirs *excelize.File, primaryKeys []string) []mergeConflict {
+	var conflicts []mergeConflict
+
+	// theirs style ids and the ids of the same styles added to ours
+	styleIDs := make(map[int]int)
+
+	baseSheets := excelBase.GetSheetList()
+	oursSheets := excelOurs.GetSheetList()
+	theirsSheets := excelTheirs.GetSheetList()
+
+	for _, sheet := range oursSheets {
+		inBase := containsString(baseSheets, sheet)
+
+		if containsString(theirsSheets, sheet) {
+			var base mergeSheetData
+			if inBase {
+				base = readMergeSheet(excelBase, sheet)
+			}
+			conflicts = append(conflicts, mergeSheet(excelOurs, excelTheirs, styleIDs, sheet, base, inBase, primaryKeys)...)
+			continue
+		}
+
+		// sheet removed in theirs
+		if !inBase {
+			continue
+		}
+		if mergeSheetsEqual(readMergeSheet(excelBase, sheet), readMergeSheet(excelOurs, sheet)) {
+			if len(excelOurs.GetSheetList()) > 1 {
+				if err := excelOurs.DeleteSheet(sheet); err != nil {
+					panic(err)
+				}
+			}
+		} else {
+			conflicts = append(conflicts, mergeConflict{sheet: sheet, reason: "sheet changed in ours and removed in theirs"})
+		}
+	}
+
+	for _, sheet := range theirsSheets {
+		if containsString(oursSheets, sheet) {
+			continue
+		}
+
+		if !containsString(baseSheets, sheet) {
+			// sheet added in theirs
+			if _, err := excelOurs.NewSheet(sheet); err != nil {
+				panic(err)
+			}
+			theirs := readMergeSheet(excelTheirs, sheet)
+			width := mergeSheetWidth(theirs)
+			for row := range theirs.values {
+				for col := 0; col < width; col++ {
+					copyMergeCell(excelOurs, excelTheirs, styleIDs, sheet, theirs, row, row, col)
+				}
+			}
+		} else if !mergeSheetsEqual(readMergeSheet(excelBase, sheet), readMergeSheet(excelTheirs, sheet)) {
+			conflicts = append(conflicts, mergeConflict{sheet: sheet, reason: "sheet removed in ours and changed in theirs"})
+		}
+	}
+
+	return conflicts
+}
+
+// merges one sheet of theirs into the same sheet of ours
+func mergeSheet(excelOurs, excelTheirs *excelize.File, styleIDs map[int]int, sheet string, base mergeSheetData, inBase bool, primaryKeys []string) []mergeConflict {
+	ours := readMergeSheet(excelOurs, sheet)
+	theirs := readMergeSheet(excelTheirs, sheet)
+
+	if inBase && mergeSheetsEqual(base, theirs) {
+		return nil
+	}
+
+	width := mergeSheetWidth(base, ours, theirs)
+	var plan sheetMergePlan
+
+	if inBase && mergeSheetsEqual(base, ours) {
+		// only theirs changed so ours becomes theirs
+		for row := 0; row < max(len(ours.values), len(theirs.values)); row++ {
+			for col := 0; col < width; col++ {
+				if mergeCellContent(ours, row, col) != mergeCellContent(theirs, row, col) {
+					plan.cellUpdates = append(plan.cellUpdates, cellUpdate{oursRow: row, theirsRow: row, col: col})
+				}
+			}
+		}
+	} else {
+		plan = planKeyedMerge(sheet, base, ours, theirs, inBase, width, primaryKeys)
+	}
+
+	applyMergePlan(excelOurs, excelTheirs, styleIDs, sheet, theirs, plan)
+
+	conflicts := plan.conflicts
+	for index := range conflicts {
+		if plan.conflictAt[index] < 0 || conflicts[index].cell == "" {
+			continue
+		}
+
+		// the cell was found before rows were added and removed
+		col, _, err := excelize.CellNameToCoordinates(conflicts[index].cell)
+		if err != nil {
+			continue
+		}
+		conflicts[index].cell, _ = excelize.CoordinatesToCellName(col, mergedRow(plan, plan.conflictAt[index])+1)
+	}
+
+	return conflicts
+}
+
+// plans a merge of the rows of theirs into ours by matching rows with a primary key
+func planKeyedMerge(sheet string, base, ours, theirs mergeSheetData, inBase bool, width int, primaryKeys []string) sheetMergePlan {
+	var plan sheetMergePlan
+
+	addConflict := func(oursRow int, conflict mergeConflict) {
+		conflict.sheet = sheet
+		plan.conflicts = append(plan.conflicts, conflict)
+		plan.conflictAt = append(plan.conflictAt, oursRow)
+	}
+
+	padded := func(data mergeSheetData) [][]string {
+		var rows [][]string
+		for _, row := range data.values {
+			for len(row) < width {
+				row = append(row, "")
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+
+	oursRows := padded(ours)
+	theirsRows := padded(theirs)
+	baseRows := padded(base)
+
+	headerRow := detectHeaderRow(oursRows)
+
+	sameHeader := headerRow < len(theirsRows) && rowToString(oursRows[headerRow]) == rowToString(theirsRows[headerRow])
+	if inBase {
+		sameHeader = sameHeader && headerRow < len(baseRows) && rowToString(oursRows[headerRow]) == rowToString(baseRows[headerRow])
+	}
+
+	var keyIndexes []int
+	if sameHeader {
+		keys := primaryKeys
+		if len(keys) == 0 {
+			keys = autoFindPrimaryKeyNames(oursRows[headerRow:], theirsRows[headerRow:])
+		}
+		keyIndexes = findPrimaryKeyIndexes(oursRows[headerRow], keys)
+
+		if len(keyIndexes) == 0 || primaryKeysUnique(oursRows[headerRow:], keyIndexes) != nil || primaryKeysUnique(theirsRows[headerRow:], keyIndexes) != nil ||
+			(inBase && primaryKeysUnique(baseRows[headerRow:], keyIndexes) != nil) {
+			keyIndexes = nil
+		}
+	}
+
+	if len(keyIndexes) == 0 {
+		reason := "sheet changed in both and no unique primary key was found to merge rows"
+		if !sameHeader {
+			reason = "sheet changed in both and the header rows are different"
+		}
+		addConflict(-1, mergeConflict{reason: reason})
+		return plan
+	}
+
+	if verboseOutput {
+		fmt.Printf("Merging %s using key columns %v\n", sheet, keyIndexes)
+	}
+
+	header := oursRows[headerRow]
+	keyString := func(row []string) string {
+		var parts []string
+		for _, index := range keyIndexes {
+			parts = append(parts, header[index]+"="+row[index])
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	// merges one cell and records a conflict when both sides changed it differently
+	mergeCell := func(baseRow, oursRow, theirsRow int, col int, key string) {
+		oursContent := mergeCellContent(ours, oursRow, col)
+		theirsContent := mergeCellContent(theirs, theirsRow, col)
+		if oursContent == theirsContent {
+			return
+		}
+
+		if baseRow >= 0 {
+			baseContent := mergeCellContent(base, baseRow, col)
+			if oursContent == baseContent {
+				plan.cellUpdates = append(plan.cellUpdates, cellUpdate{oursRow: oursRow, theirsRow: theirsRow, col: col})
+				return
+			}
+			if theirsContent == baseContent {
+				return
+			}
+		}
+
+		cellName, _ := excelize.CoordinatesToCellName(col+1, oursRow+1)
+		baseText := ""
+		if baseRow >= 0 {
+			baseText = mergeCellText(base, baseRow, col)
+		}
+		addConflict(oursRow, mergeConflict{cell: cellName, key: key, reason: "cell changed in both", base: baseText,
+			ours: mergeCellText(ours, oursRow, col), theirs: mergeCellText(theirs, theirsRow, col)})
+	}
+
+	// rows above the header are merged by position
+	for row := 0; row < headerRow; row++ {
+		baseRow := -1
+		if inBase && row < len(baseRows) {
+			baseRow = row
+		}
+		for col := 0; col < width; col++ {
+			mergeCell(baseRow, row, row, col, "")
+		}
+	}
+
+	basePositions := make(map[string]int)
+	if inBase {
+		for index := headerRow + 1; index < len(baseRows); index++ {
+			basePositions[concatKeysData(baseRows[index], keyIndexes)] = index
+		}
+	}
+
+	oursPositions := make(map[string]int)
+	for index := headerRow + 1; index < len(oursRows); index++ {
+		oursPositions[concatKeysData(oursRows[index], keyIndexes)] = index
+	}
+
+	theirsPositions := make(map[string]int)
+	for index := headerRow + 1; index < len(theirsRows); index++ {
+		theirsPositions[concatKeysData(theirsRows[index], keyIndexes)] = index
+	}
+
+	for oursRow := headerRow + 1; oursRow < len(oursRows); oursRow++ {
+		key := concatKeysData(oursRows[oursRow], keyIndexes)
+		baseRow, rowInBase := basePositions[key]
+		if !rowInBase {
+			baseRow = -1
+		}
+		theirsRow, rowInTheirs := theirsPositions[key]
+
+		if rowInTheirs {
+			for col := 0; col < width; col++ {
+				mergeCell(baseRow, oursRow, theirsRow, col, keyString(oursRows[oursRow]))
+			}
+			continue
+		}
+
+		// row removed in theirs
+		if !rowInBase {
+			continue
+		}
+		if rowToString(oursRows[oursRow]) == rowToString(baseRows[baseRow]) && mergeRowContent(ours, oursRow, width) == mergeRowContent(base, baseRow, width) {
+			plan.deletions = append(plan.deletions, oursRow)
+		} else {
+			addConflict(oursRow, mergeConflict{key: keyString(oursRows[oursRow]), reason: "row changed in ours and removed in theirs",
+				base: strings.Join(baseRows[baseRow], ", "), ours: strings.Join(oursRows[oursRow], ", ")})
+		}
+	}
+
+	for theirsRow := headerRow + 1; theirsRow < len(theirsRows); theirsRow++ {
+		key := concatKeysData(theirsRows[theirsRow], keyIndexes)
+		if _, ok := oursPositions[key]; ok {
+			continue
+		}
+
+		if baseRow, ok := basePositions[key]; ok {
+			// row removed in ours
+			if mergeRowContent(theirs, theirsRow, width) != mergeRowContent(base, baseRow, width) {
+				addConflict(-1, mergeConflict{key: keyString(theirsRows[theirsRow]), reason: "row removed in ours and changed in theirs",
+					base: strings.Join(baseRows[baseRow], ", "), theirs: strings.Join(theirsRows[theirsRow], ", ")})
+			}
+			continue
+		}
+
+		// row added in theirs goes after the closest row before it that is in ours
+		anchor := headerRow
+		for index := theirsRow - 1; index > headerRow; index-- {
+			if oursRow, ok := oursPositions[concatKeysData(theirsRows[index], keyIndexes)]; ok {
+				anchor = oursRow
+				break
+			}
+		}
+		plan.insertions = append(plan.insertions, rowInsertion{afterOursRow: anchor, theirsRow: theirsRow})
+	}
+
+	return plan
+}
+
+// the row in the merged sheet of a row of ours
+func mergedRow(plan sheetMergePlan, oursRow int) int {
+	row := oursRow
+	for _, deleted := range plan.deletions {
+		if deleted < oursRow {
+			row--
+		}
+	}
+	for _, insertion := range plan.insertions {
+		if insertion.afterOursRow < oursRow {
+			row++
+		}
+	}
+	return row
+}
+
+// applies the plan to the ours workbook from the bottom of the sheet up so the
+// planned rows stay valid as rows are added and removed
+func applyMergePlan(excelOurs, excelTheirs *excelize.File, styleIDs map[int]int, sheet string, theirs mergeSheetData, plan sheetMergePlan) {
+	for _, update := range plan.cellUpdates {
+		copyMergeCell(excelOurs, excelTheirs, styleIDs, sheet, theirs, update.theirsRow, update.oursRow, update.col)
+	}
+
+	type rowOperation struct {
+		oursRow   int
+		insertion bool
+		theirsRow int
+	}
+
+	var operations []rowOperation
+	for _, deleted := range plan.deletions {
+		operations = append(operations, rowOperation{oursRow: deleted})
+	}
+	for _, insertion := range plan.insertions {
+		operations = append(operations, rowOperation{oursRow: insertion.afterOursRow, insertion: true, theirsRow: insertion.theirsRow})
+	}
+
+	// insertions after a row go before removing that row and are done in
+	// reverse so they end up in theirs order
+	sort.SliceStable(operations, func(i, j int) bool {
+		if operations[i].oursRow != operations[j].oursRow {
+			return operations[i].oursRow > operations[j].oursRow
+		}
+		if operations[i].insertion != operations[j].insertion {
+			return operations[i].insertion
+		}
+		return operations[i].theirsRow > operations[j].theirsRow
+	})
+
+	width := mergeSheetWidth(theirs)
+
+	for _, operation := range operations {
+		if !operation.insertion {
+			if err := excelOurs.RemoveRow(sheet, operation.oursRow+1); err != nil {
+				panic(err)
+			}
+			continue
+		}
+
+		if err := excelOurs.InsertRows(sheet, operation.oursRow+2, 1); err != nil {
+			panic(err)
+		}
+		for col := 0; col < width; col++ {
+			copyMergeCell(excelOurs, excelTheirs, styleIDs, sheet, theirs, operation.theirsRow, operation.oursRow+1, col)
+		}
+	}
+}
+
+// copies the value, formula and style of a cell of theirs into ours
+func copyMergeCell(excelOurs, excelTheirs *excelize.File, styleIDs map[int]int, sheet string, theirs mergeSheetData, theirsRow int, oursRow int, col int) {
+	theirsCell, err := excelize.CoordinatesToCellName(col+1, theirsRow+1)
+	if err != nil {
+		panic(err)
+	}
+	oursCell, err := excelize.CoordinatesToCellName(col+1, oursRow+1)
+	if err != nil {
+		panic(err)
+	}
+
+	value := detailAt(theirs.values, theirsRow, col)
+
+	cellType, err := excelTheirs.GetCellType(sheet, theirsCell)
+	if err != nil {
+		panic(err)
+	}
+
+	var typedValue interface{} = value
+	if value == "" {
+		typedValue = nil
+	} else if cellType == excelize.CellTypeBool {
+		typedValue = value == "1" || strings.EqualFold(value, "true")
+	} else if cellType != excelize.CellTypeSharedString && cellType != excelize.CellTypeInlineString {
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			typedValue = number
+		}
+	}
+
+	if err := excelOurs.SetCellValue(sheet, oursCell, typedValue); err != nil {
+		panic(err)
+	}
+
+	if formula := detailAt(theirs.formulas, theirsRow, col); formula != "" {
+		if err := excelOurs.SetCellFormula(sheet, oursCell, strings.TrimPrefix(formula, "=")); err != nil {
+			panic(err)
+		}
+	}
+	styleID, err := excelTheirs.GetCellStyle(sheet, theirsCell)
+	if err != nil {
+		panic(err)
+	}
+	if err := excelOurs.SetCellStyle(sheet, oursCell, oursCell, oursStyleID(excelOurs, excelTheirs, styleIDs, styleID)); err != nil {
+		panic(err)
+	}
+}
+
+// the id in ours of a style of theirs. Style ids are different in every
+// workbook so the style is added to ours the first time it is used
+func oursStyleID(excelOurs, excelTheirs *excelize.File, styleIDs map[int]int, theirsStyleID int) int {
+	if theirsStyleID == 0 {
+		return 0
+	}
+	if styleID, ok := styleIDs[theirsStyleID]; ok {
+		return styleID
+	}
+
+	style, err := excelTheirs.GetStyle(theirsStyleID)
+	if err != nil {
+		panic(err)
+	}
+	styleID, err := excelOurs.NewStyle(style)
+	if err != nil {
+		panic(err)
+	}
+
+	styleIDs[theirsStyleID] = styleID
+	return styleID
+}
+
+// lists the conflicts on a new sheet of the merged workbook
+func addConflictSheet(excelFile *excelize.File, conflicts []mergeConflict) {
+	sheet := "Conflicts"
+	for index := 2; containsString(excelFile.GetSheetList(), sheet); index++ {
+		sheet = "Conflicts " + strconv.Itoa(index)
+	}
+
+	if _, err := excelFile.NewSheet(sheet); err != nil {
+		panic(err)
+	}
+
+	header := []interface{}{"Sheet", "Cell", "Key", "Conflict", "Base", "Ours", "Theirs"}
+	if err := excelFile.SetSheetRow(sheet, "A1", &header); err != nil {
+		panic(err)
+	}
+
+	for index, conflict := range conflicts {
+		row := []interface{}{conflict.sheet, conflict.cell, conflict.key, conflict.reason, conflict.base, conflict.ours, conflict.theirs}
+		cellName, err := excelize.CoordinatesToCellName(1, index+2)
+		if err != nil {
+			panic(err)
+		}
+		if err := excelFile.SetSheetRow(sheet, cellName, &row); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// a workbook with the rows on Sheet1
+func newMergeWorkbook(t *testing.T, rows [][]interface{}) *excelize.File {
+	t.Helper()
+
+	excelFile := excelize.NewFile()
+	for index, row := range rows {
+		cellName, _ := excelize.CoordinatesToCellName(1, index+1)
+		if err := excelFile.SetSheetRow("Sheet1", cellName, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return excelFile
+}
+
+func mergedRows(t *testing.T, excelFile *excelize.File, sheet string) [][]string {
+	t.Helper()
+
+	rows, err := excelFile.GetRows(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestMergeWorkbooks(t *testing.T) {
+	base := [][]interface{}{{"ID", "Name", "Qty"}, {1, "a", 5}, {2, "b", 6}, {3, "c", 7}}
+
+	tests := []struct {
+		name      string
+		ours      [][]interface{}
+		theirs    [][]interface{}
+		merged    [][]string
+		conflicts []mergeConflict
+	}{
+		{
+			name:   "only theirs changed",
+			ours:   base,
+			theirs: [][]interface{}{{"ID", "Name", "Qty"}, {1, "a", 5}, {2, "bb", 6}, {3, "c", 7}},
+			merged: [][]string{{"ID", "Name", "Qty"}, {"1", "a", "5"}, {"2", "bb", "6"}, {"3", "c", "7"}},
+		},
+		{
+			name:   "only ours changed",
+			ours:   [][]interface{}{{"ID", "Name", "Qty"}, {1, "aa", 5}, {2, "b", 6}, {3, "c", 7}},
+			theirs: base,
+			merged: [][]string{{"ID", "Name", "Qty"}, {"1", "aa", "5"}, {"2", "b", "6"}, {"3", "c", "7"}},
+		},
+		{
+			name:   "different cells changed",
+			ours:   [][]interface{}{{"ID", "Name", "Qty"}, {1, "aa", 5}, {2, "b", 6}, {3, "c", 7}},
+			theirs: [][]interface{}{{"ID", "Name", "Qty"}, {1, "a", 5}, {2, "b", 60}, {3, "c", 7}},
+			merged: [][]string{{"ID", "Name", "Qty"}, {"1", "aa", "5"}, {"2", "b", "60"}, {"3", "c", "7"}},
+		},
+		{
+			name:   "same change in both",
+			ours:   [][]interface{}{{"ID", "Name", "Qty"}, {1, "aa", 5}, {2, "b", 6}, {3, "c", 7}},
+			theirs: [][]interface{}{{"ID", "Name", "Qty"}, {1, "aa", 5}, {2, "b", 6}, {3, "c", 8}},
+			merged: [][]string{{"ID", "Name", "Qty"}, {"1", "aa", "5"}, {"2", "b", "6"}, {"3", "c", "8"}},
+		},
+		{
+			name:   "same cell changed in both",
+			ours:   [][]interface{}{{"ID", "Name", "Qty"}, {1, "a", 5}, {2, "ours", 6}, {3, "c", 7}},
+			theirs: [][]interface{}{{"ID", "Name", "Qty"}, {1, "a", 50}, {2, "theirs", 6}, {3, "c", 7}},
+			merged: [][]string{{"ID", "Name", "Qty"}, {"1", "a", "50"}, {"2", "ours", "6"}, {"3", "c", "7"}},
+			conflicts: []mergeConflict{
+				{sheet: "Sheet1", cell: "B3", key: "ID=2", reason: "cell changed in both", base: "b", ours: "ours", theirs: "theirs"},
+			},
+		},
+		{
+			name:   "rows added in both",
+			ours:   [][]interface{}{{"ID", "Name", "Qty"}, {1, "a", 5}, {4, "ours", 1}, {2, "b", 6}, {3, "c", 7}},
+			theirs: [][]interface{}{{"ID", "Name", "Qty"}, {1, "a", 5}, {2, "b", 6}, {5, "theirs", 2}, {3, "c", 7}, {6, "last", 3}},
+			merged: [][]string{{"ID", "Name", "Qty"}, {"1", "a", "5"}, {"4", "ours", "1"}, {"2", "b", "6"}, {"5", "theirs", "2"}, {"3", "c", "7"}, {"6", "last", "3"}},
+		},
+		{
+			name:   "row removed in theirs",
+			ours:   [][]interface{}{{"ID", "Name", "Qty"}, {1, "aa", 5}, {2, "b", 6}, {3, "c", 7}},
+			theirs: [][]interface{}{{"ID", "Name", "Qty"}, {1, "a", 5}, {3, "c", 7}},
+			merged: [][]string{{"ID", "Name", "Qty"}, {"1", "aa", "5"}, {"3", "c", "7"}},
+		},
+		{
+			name:   "row changed in ours and removed in theirs",
+			ours:   [][]interface{}{{"ID", "Name", "Qty"}, {1, "a", 5}, {2, "bb", 6}, {3, "c", 7}},
+			theirs: [][]interface{}{{"ID", "Name", "Qty"}, {1, "a", 5}, {3, "c", 70}},
+			merged: [][]string{{"ID", "Name", "Qty"}, {"1", "a", "5"}, {"2", "bb", "6"}, {"3", "c", "70"}},
+			conflicts: []mergeConflict{
+				{sheet: "Sheet1", key: "ID=2", reason: "row changed in ours and removed in theirs", base: "2, b, 6", ours: "2, bb, 6"},
+			},
+		},
+		{
+			name:   "row removed in ours and changed in theirs",
+			ours:   [][]interface{}{{"ID", "Name", "Qty"}, {1, "aa", 5}, {3, "c", 7}},
+			theirs: [][]interface{}{{"ID", "Name", "Qty"}, {1, "a", 5}, {2, "bb", 6}, {3, "c", 7}},
+			merged: [][]string{{"ID", "Name", "Qty"}, {"1", "aa", "5"}, {"3", "c", "7"}},
+			conflicts: []mergeConflict{
+				{sheet: "Sheet1", key: "ID=2", reason: "row removed in ours and changed in theirs", base: "2, b, 6", theirs: "2, bb, 6"},
+			},
+		},
+		{
+			name:   "header changed in theirs",
+			ours:   [][]interface{}{{"ID", "Name", "Qty"}, {1, "aa", 5}, {2, "b", 6}, {3, "c", 7}},
+			theirs: [][]interface{}{{"ID", "Title", "Qty"}, {1, "a", 5}, {2, "b", 6}, {3, "c", 70}},
+			merged: [][]string{{"ID", "Name", "Qty"}, {"1", "aa", "5"}, {"2", "b", "6"}, {"3", "c", "7"}},
+			conflicts: []mergeConflict{
+				{sheet: "Sheet1", reason: "sheet changed in both and the header rows are different"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			excelBase := newMergeWorkbook(t, base)
+			excelOurs := newMergeWorkbook(t, test.ours)
+			excelTheirs := newMergeWorkbook(t, test.theirs)
+
+			conflicts := mergeWorkbooks(excelBase, excelOurs, excelTheirs, []string{"ID"})
+
+			if !reflect.DeepEqual(conflicts, test.conflicts) {
+				t.Errorf("conflicts %+v, want %+v", conflicts, test.conflicts)
+			}
+			if merged := mergedRows(t, excelOurs, "Sheet1"); !reflect.DeepEqual(merged, test.merged) {
+				t.Errorf("merged %q, want %q", merged, test.merged)
+			}
+		})
+	}
+}
+
+func TestMergeWorkbooksSheets(t *testing.T) {
+	base := [][]interface{}{{"ID", "Name"}, {1, "a"}}
+
+	excelBase := newMergeWorkbook(t, base)
+	excelOurs := newMergeWorkbook(t, base)
+	excelTheirs := newMergeWorkbook(t, base)
+
+	if _, err := excelBase.NewSheet("Removed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := excelOurs.NewSheet("Removed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := excelTheirs.NewSheet("Added"); err != nil {
+		t.Fatal(err)
+	}
+	if err := excelTheirs.SetSheetRow("Added", "A1", &[]interface{}{"New", 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if conflicts := mergeWorkbooks(excelBase, excelOurs, excelTheirs, nil); len(conflicts) > 0 {
+		t.Fatalf("unexpected conflicts %+v", conflicts)
+	}
+
+	if sheets := excelOurs.GetSheetList(); !reflect.DeepEqual(sheets, []string{"Sheet1", "Added"}) {
+		t.Errorf("sheets %q, want Sheet1 and Added", sheets)
+	}
+	if rows := mergedRows(t, excelOurs, "Added"); !reflect.DeepEqual(rows, [][]string{{"New", "1"}}) {
+		t.Errorf("added sheet %q", rows)
+	}
+}
+
+func TestMergeWorkbooksStyles(t *testing.T) {
+	base := [][]interface{}{{"ID", "Name"}, {1, "a"}, {2, "b"}}
+
+	excelBase := newMergeWorkbook(t, base)
+	excelOurs := newMergeWorkbook(t, [][]interface{}{{"ID", "Name"}, {1, "aa"}, {2, "b"}})
+	excelTheirs := newMergeWorkbook(t, [][]interface{}{{"ID", "Name"}, {1, "a"}, {2, "b"}, {3, "c"}})
+
+	bold, err := excelTheirs.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill, err := excelTheirs.NewStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Color: []string{"FFFF00"}, Pattern: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a style change on a row that did not change and a new styled row
+	if err := excelTheirs.SetCellStyle("Sheet1", "B3", "B3", fill); err != nil {
+		t.Fatal(err)
+	}
+	if err := excelTheirs.SetCellStyle("Sheet1", "A4", "B4", bold); err != nil {
+		t.Fatal(err)
+	}
+
+	if conflicts := mergeWorkbooks(excelBase, excelOurs, excelTheirs, []string{"ID"}); len(conflicts) > 0 {
+		t.Fatalf("unexpected conflicts %+v", conflicts)
+	}
+
+	theirsStyles := readMergeStyles(excelTheirs, "Sheet1")
+	oursStyles := readMergeStyles(excelOurs, "Sheet1")
+	for _, cell := range []struct{ row, col int }{{2, 1}, {3, 0}, {3, 1}} {
+		if detailAt(oursStyles, cell.row, cell.col) != detailAt(theirsStyles, cell.row, cell.col) {
+			t.Errorf("row %d column %d has style %q, want %q", cell.row+1, cell.col+1, detailAt(oursStyles, cell.row, cell.col), detailAt(theirsStyles, cell.row, cell.col))
+		}
+	}
+	if detailAt(oursStyles, 1, 1) != "" {
+		t.Errorf("unchanged cell has style %q", detailAt(oursStyles, 1, 1))
+	}
+}
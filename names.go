@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type definedNameChange struct {
+	changeType differenceType
+	theirs     excelize.DefinedName
+	mine       excelize.DefinedName
+}
+
+func definedNameKey(name excelize.DefinedName) string {
+	return name.Scope + "%@!#!@%" + name.Name
+}
+
+// lists the defined names that were added, removed or changed. Names are
+// matched by their name and scope
+func compareDefinedNames(excelTheirs, excelMine *excelize.File) []definedNameChange {
+	var changes []definedNameChange
+
+	theirsNames := make(map[string]excelize.DefinedName)
+	for _, name := range excelTheirs.GetDefinedName() {
+		theirsNames[definedNameKey(name)] = name
+	}
+
+	mineNames := make(map[string]excelize.DefinedName)
+	for _, name := range excelMine.GetDefinedName() {
+		mineNames[definedNameKey(name)] = name
+	}
+
+	for key, mineName := range mineNames {
+		theirsName, ok := theirsNames[key]
+		if !ok {
+			changes = append(changes, definedNameChange{changeType: addition, mine: mineName})
+		} else if theirsName != mineName {
+			changes = append(changes, definedNameChange{changeType: difference, theirs: theirsName, mine: mineName})
+		}
+	}
+
+	for key, theirsName := range theirsNames {
+		if _, ok := mineNames[key]; !ok {
+			changes = append(changes, definedNameChange{changeType: deletion, theirs: theirsName})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return definedNameKey(definedNameChangeName(changes[i])) < definedNameKey(definedNameChangeName(changes[j]))
+	})
+
+	return changes
+}
+
+// the name from whichever side has it
+func definedNameChangeName(change definedNameChange) excelize.DefinedName {
+	if change.changeType == deletion {
+		return change.theirs
+	}
+	return change.mine
+}
+
+func definedNameChangeString(change definedNameChange) string {
+	switch change.changeType {
+	case addition:
+		return "Name added"
+	case deletion:
+		return "Name removed"
+	default:
+		return "Name changed"
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// a workbook changed in a commit range and the diff written for it
+type rangeFile struct {
+	path    string
+	oldPath string // path at the from commit if the workbook was renamed
+	status  string
+	report  string // file name of the diff in the report directory
+	counts  diffCounts
+	err     string // why the workbook could not be diffed, blank if it was
+}
+
+func rangeUsage() {
+	fmt.Printf("Usage: ged range [arguments] <rev range>\n")
+	fmt.Printf("Diffs every workbook changed in the range, e.g. main..feature, main...feature or v1.0 (the same as v1.0..HEAD)\n")
+}
+
+// ged range diffs every workbook changed in a commit range and writes the
+// diffs and an index page to one report directory
+func runRange(args []string) {
+	flags := flag.NewFlagSet("range", flag.ExitOnError)
+	var keyFlag = flags.String("k", "", "The primary key to be used for diffing the excel sheets")
+	var outputFlag = flags.String("o", "", "Path to the report directory. Default is ged-range-<rev range> in the current working directory")
+	var smartCompareOffFlag = flags.Bool("sco", false, "Tells ged to turn of smart compare and ignore primary keys")
+	var verboseFlag = flags.Bool("v", false, "Display verbose output")
+	var formulaFlag = flags.Bool("f", false, "Compare cell formulas as well as their values")
+	var styleFlag = flags.Bool("s", false, "Compare cell styles as well as their values")
+	var sheetRegions = regionFlags{}
+	flags.Var(sheetRegions, "region", "Header row and data range of a sheet as <sheet>!<range>. Can be repeated")
+	flags.Usage = func() {
+		rangeUsage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	verboseOutput = *verboseFlag
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	revRange := flags.Arg(0)
+
+	currentDir, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Unable to find current directory. Aborting..\r\n")
+		panic(err)
+	}
+
+	gitRoot, err := runGit("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		fmt.Printf("Error: Unable to find git root folder: %s\n", err)
+		os.Exit(1)
+	}
+	gitRoot = filepath.FromSlash(gitRoot)
+
+	from, to, err := resolveRefRange(gitRoot, revRange, "")
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	if to == "" {
+		to = "HEAD"
+	}
+
+	files, err := changedWorkbooks(gitRoot, from, to)
+	if err != nil {
+		fmt.Printf("Error: Unable to list changed files: %s\n", err)
+		os.Exit(1)
+	}
+
+	reportDir := filepath.Join(currentDir, "ged-range-"+reportFileName(revRange))
+	if *outputFlag != "" {
+		reportDir = absolutePath(currentDir, filepath.FromSlash(*outputFlag))
+	}
+
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		panic(err)
+	}
+
+	regions, err := readRegionConfig(filepath.Join(gitRoot, regionConfigFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("Error: Unable to read %s: %s\n", regionConfigFile, err)
+		os.Exit(1)
+	}
+
+	tempDir, err := os.MkdirTemp("", "ged-range")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	primaryKeyList := []string{}
+	if *keyFlag != "" {
+		primaryKeyList = append(primaryKeyList, *keyFlag)
+	}
+	options := diffOptions{primaryKeys: primaryKeyList, formulas: *formulaFlag, styles: *styleFlag, regions: sheetRegions, smartCompare: !*smartCompareOffFlag}
+
+	fmt.Printf("Diffing %d workbooks changed between %s and %s\r\n", len(files), from, to)
+
+	reportNames := make(map[string]bool)
+	failed := 0
+
+	for index, file := range files {
+		fmt.Printf("Diffing %s (%s)\r\n", file.path, file.status)
+
+		files[index].report = uniqueReportName(reportNames, file.path)
+
+		options.regions, err = regions.regionsFor(file.path, sheetRegions)
+		if err != nil {
+			err = fmt.Errorf("unable to read %s: %w", regionConfigFile, err)
+		} else {
+			files[index].counts, err = diffRangeFile(gitRoot, from, to, tempDir, index, file, filepath.Join(reportDir, files[index].report), options)
+		}
+
+		// the other workbooks are still diffed when one of them can't be
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			files[index].err = err.Error()
+			failed++
+		}
+	}
+
+	indexPath := filepath.Join(reportDir, "index.html")
+	if err := os.WriteFile(indexPath, []byte(htmlAddRangeIndex(revRange, files)), 0644); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Report written to %s\r\n", indexPath)
+
+	if failed > 0 {
+		fmt.Printf("Error: %d of %d workbooks could not be diffed\n", failed, len(files))
+		os.RemoveAll(tempDir)
+		os.Exit(1)
+	}
+}
+
+// writes the theirs and mine versions of a changed workbook to the temp
+// directory and diffs them into the report
+func diffRangeFile(gitRoot string, from string, to string, tempDir string, index int, file rangeFile, reportPath string, options diffOptions) (diffCounts, error) {
+	ext := filepath.Ext(file.path)
+
+	// added and deleted workbooks are compared against an empty workbook
+	theirWorkBook := os.DevNull
+	theirsPath := file.path
+	if file.oldPath != "" {
+		theirsPath = file.oldPath
+	}
+	if file.status != "added" {
+		theirWorkBook = filepath.Join(tempDir, fmt.Sprintf("%d-Theirs%s", index, ext))
+		if err := writeGitBlob(gitRoot, from, theirsPath, theirWorkBook); err != nil {
+			return diffCounts{}, fmt.Errorf("unable to read %s at %s: %w", theirsPath, from, err)
+		}
+	}
+
+	mineWorkBook := os.DevNull
+	if file.status != "deleted" {
+		mineWorkBook = filepath.Join(tempDir, fmt.Sprintf("%d-Mine%s", index, ext))
+		if err := writeGitBlob(gitRoot, to, file.path, mineWorkBook); err != nil {
+			return diffCounts{}, fmt.Errorf("unable to read %s at %s: %w", file.path, to, err)
+		}
+	}
+
+	header := diffHeader{theirs: theirsPath + " at " + from, mine: file.path + " at " + to}
+	if file.oldPath != "" {
+		header.rename = fileRename{from: file.oldPath, to: file.path}
+	}
+
+	counts, err := diffWorkbooks(theirWorkBook, mineWorkBook, reportPath, header, options)
+	if err != nil {
+		return diffCounts{}, fmt.Errorf("unable to open %s: %w", file.path, err)
+	}
+
+	return counts, nil
+}
+
+// lists the workbooks that were added, deleted, renamed or modified between two commits
+func changedWorkbooks(gitRoot string, from string, to string) ([]rangeFile, error) {
+	args := append([]string{"-c", "core.quotePath=false", "diff", "-M", "--name-status", from, to, "--"}, workbookPathspecs...)
+	output, err := runGit(gitRoot, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []rangeFile
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+
+		file := rangeFile{path: fields[len(fields)-1]}
+		switch {
+		case fields[0] == "A":
+			file.status = "added"
+		case fields[0] == "D":
+			file.status = "deleted"
+		case strings.HasPrefix(fields[0], "R") && len(fields) == 3:
+			file.status = "renamed"
+			file.oldPath = fields[1]
+		default:
+			file.status = "modified"
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+var reportFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// a name that is safe to use as a file name in the report directory
+func reportFileName(name string) string {
+	return reportFileNameChars.ReplaceAllString(name, "_")
+}
+
+// the file name of the diff of a workbook in the report directory. Paths like
+// a/b.xlsx and a_b.xlsx give the same safe name so a number is added to the
+// names after the first. Names are compared ignoring case for file systems
+// that do
+func uniqueReportName(used map[string]bool, path string) string {
+	name := reportFileName(path)
+	report := name + "-diff.html"
+	for count := 2; used[strings.ToLower(report)]; count++ {
+		report = name + "-" + strconv.Itoa(count) + "-diff.html"
+	}
+
+	used[strings.ToLower(report)] = true
+	return report
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUniqueReportName(t *testing.T) {
+	used := make(map[string]bool)
+
+	for _, test := range []struct {
+		path   string
+		report string
+	}{
+		{path: "a/b.xlsx", report: "a_b.xlsx-diff.html"},
+		{path: "a_b.xlsx", report: "a_b.xlsx-2-diff.html"},
+		{path: "a b.xlsx", report: "a_b.xlsx-3-diff.html"},
+		{path: "A_B.xlsx", report: "A_B.xlsx-4-diff.html"},
+		{path: "c.xlsx", report: "c.xlsx-diff.html"},
+	} {
+		if report := uniqueReportName(used, test.path); report != test.report {
+			t.Errorf("%s: got %s, want %s", test.path, report, test.report)
+		}
+	}
+}
+
+func TestChangedWorkbooks(t *testing.T) {
+	dir := newTestRepo(t)
+
+	writeTestFile(t, dir, "modified.xlsx", "v1\n")
+	writeTestFile(t, dir, "deleted.xlsm", "v1\n")
+	writeTestFile(t, dir, "old.XLSX", "same content\n")
+	writeTestFile(t, dir, "notes.txt", "v1\n")
+	from := testCommit(t, dir, "first")
+
+	writeTestFile(t, dir, "modified.xlsx", "v2\n")
+	writeTestFile(t, dir, "reports/Report.XLSX", "new\n")
+	writeTestFile(t, dir, "notes.txt", "v2\n")
+	testGit(t, dir, "rm", "-q", "deleted.xlsm")
+	testGit(t, dir, "mv", "old.XLSX", "New.Xlsx")
+	to := testCommit(t, dir, "second")
+
+	files, err := changedWorkbooks(dir, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []rangeFile
+	for _, file := range files {
+		got = append(got, rangeFile{path: file.path, oldPath: file.oldPath, status: file.status})
+	}
+
+	want := []rangeFile{
+		{path: "New.Xlsx", oldPath: "old.XLSX", status: "renamed"},
+		{path: "deleted.xlsm", status: "deleted"},
+		{path: "modified.xlsx", status: "modified"},
+		{path: "reports/Report.XLSX", status: "added"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// the part of a sheet that holds the table being diffed. All values are zero
+// based indexes into the sheet
+type sheetRegion struct {
+	headerRow int
+	lastRow   int // -1 to use every row after the header
+	firstCol  int
+	lastCol   int // -1 to use every column after the first
+}
+
+// regions given by the user with the -region flag, keyed by sheet name. The
+// sheet name "*" applies to every sheet without its own region
+type regionFlags map[string]sheetRegion
+
+func (regions regionFlags) String() string {
+	var entries []string
+	for sheet, region := range regions {
+		entries = append(entries, fmt.Sprintf("%s!%+v", sheet, region))
+	}
+	return strings.Join(entries, ";")
+}
+
+func (regions regionFlags) Set(value string) error {
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		separator := strings.LastIndex(entry, "!")
+		if separator < 1 {
+			return errors.New("region " + entry + " must be in the form <sheet>!<range>")
+		}
+
+		region, err := parseRegion(entry[separator+1:])
+		if err != nil {
+			return err
+		}
+
+		regions[entry[:separator]] = region
+	}
+	return nil
+}
+
+// the name of the file in the root of the repository that holds the regions of
+// the sheets of each workbook
+const regionConfigFile = "gedRegions.json"
+
+type regionConfig struct {
+	Workbooks []workbookRegions `json:"workbooks"`
+}
+
+// the regions of the sheets of the workbooks whose git path matches the pattern,
+// in the same form as the -region flag
+type workbookRegions struct {
+	Pattern string            `json:"pattern"`
+	Regions map[string]string `json:"regions"`
+}
+
+func readRegionConfig(configPath string) (regionConfig, error) {
+	var config regionConfig
+
+	configBytes, err := os.ReadFile(configPath)
+	if err != nil {
+		return config, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewBuffer(configBytes))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&config)
+
+	return config, err
+}
+
+// the regions of the config for the workbook with the regions given as flags
+// taking priority. Patterns without a slash match the file name in any directory
+func (config regionConfig) regionsFor(gitPath string, flags regionFlags) (regionFlags, error) {
+	regions := regionFlags{}
+
+	for _, workbook := range config.Workbooks {
+		name := gitPath
+		if !strings.Contains(workbook.Pattern, "/") {
+			name = path.Base(gitPath)
+		}
+
+		if matched, err := path.Match(workbook.Pattern, name); err != nil || !matched {
+			continue
+		}
+
+		for sheet, spec := range workbook.Regions {
+			region, err := parseRegion(spec)
+			if err != nil {
+				return nil, err
+			}
+			regions[sheet] = region
+		}
+	}
+
+	for sheet, region := range flags {
+		regions[sheet] = region
+	}
+
+	return regions, nil
+}
+
+// the regions for the workbook from the region config in the root of the
+// repository, if there is one, and the -region flags
+func workbookRegionsFor(gitRoot string, gitPath string, flags regionFlags) regionFlags {
+	config, err := readRegionConfig(filepath.Join(gitRoot, regionConfigFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return flags
+	}
+
+	var regions regionFlags
+	if err == nil {
+		regions, err = config.regionsFor(filepath.ToSlash(gitPath), flags)
+	}
+	if err != nil {
+		fmt.Printf("Error: Unable to read %s: %s\n", regionConfigFile, err)
+		os.Exit(1)
+	}
+
+	return regions
+}
+
+func (regions regionFlags) regionFor(sheet string) *sheetRegion {
+	if region, ok := regions[sheet]; ok {
+		return &region
+	}
+	if region, ok := regions["*"]; ok {
+		return &region
+	}
+	return nil
+}
+
+// parses a region given as a header row number (5), a row range (5:100), a
+// starting cell (B5) or a cell range (B5:F100). A row range can end at a cell
+// (5:F100) to limit the columns to A through F
+func parseRegion(spec string) (sheetRegion, error) {
+	region := sheetRegion{lastRow: -1, lastCol: -1}
+
+	start, end, hasEnd := strings.Cut(strings.ToUpper(strings.TrimSpace(spec)), ":")
+
+	startCol, startRow, err := parseRegionCell(start)
+	if err != nil {
+		return region, err
+	}
+	region.headerRow = startRow
+	region.firstCol = startCol
+
+	if hasEnd {
+		endCol, endRow, err := parseRegionCell(end)
+		if err != nil {
+			return region, err
+		}
+		if endRow < startRow || (endCol >= 0 && endCol < startCol) {
+			return region, errors.New("region " + spec + " ends before it starts")
+		}
+		region.lastRow = endRow
+		if endCol >= 0 {
+			region.lastCol = endCol
+		}
+	}
+
+	region.firstCol = max(region.firstCol, 0)
+
+	return region, nil
+}
+
+// returns the zero based column and row of a cell name or a row number. The
+// column is -1 when only a row number was given
+func parseRegionCell(cell string) (int, int, error) {
+	if row, err := strconv.Atoi(cell); err == nil {
+		if row < 1 {
+			return 0, 0, errors.New("region row " + cell + " must be 1 or greater")
+		}
+		return -1, row - 1, nil
+	}
+
+	col, row, err := excelize.CellNameToCoordinates(cell)
+	if err != nil {
+		return 0, 0, errors.New("invalid region cell " + cell)
+	}
+
+	return col - 1, row - 1, nil
+}
+
+func nonEmptyCells(row []string) int {
+	count := 0
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// finds the header row by skipping title, note and blank rows above the table.
+// Those rows are expected to hold at most one value
+func detectHeaderRow(data [][]string) int {
+	widest := 0
+	for _, row := range data {
+		widest = max(widest, nonEmptyCells(row))
+	}
+
+	for index, row := range data {
+		cells := nonEmptyCells(row)
+		if cells > 1 || (cells == 1 && widest == 1) {
+			return index
+		}
+	}
+
+	return 0
+}
+
+// the number of non empty cells in the columns first to last of the row
+func nonEmptyCellsBetween(row []string, first int, last int) int {
+	count := 0
+	for col := first; col <= last; col++ {
+		if strings.TrimSpace(cellAt(row, col)) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// whether any row from the first row on has a value in the column
+func columnHasValues(data [][]string, firstRow int, col int) bool {
+	for _, row := range data[firstRow:] {
+		if strings.TrimSpace(cellAt(row, col)) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// detects the header row and the data range of the table. The table starts at
+// the first header and runs over the columns next to it that hold a header or
+// data, so an empty column ends the table. The table ends at a blank row when
+// only notes (rows with at most one value) follow it. The region only ends
+// before the last row or column when there is something after the table
+func detectRegion(data [][]string) sheetRegion {
+	region := sheetRegion{headerRow: detectHeaderRow(data), lastRow: -1, lastCol: -1}
+	if region.headerRow >= len(data) {
+		return region
+	}
+
+	first := -1
+	for col, cell := range data[region.headerRow] {
+		if strings.TrimSpace(cell) != "" {
+			first = col
+			break
+		}
+	}
+	if first < 0 {
+		return region
+	}
+
+	last := first
+	for first > 0 && columnHasValues(data, region.headerRow, first-1) {
+		first--
+	}
+	for columnHasValues(data, region.headerRow, last+1) {
+		last++
+	}
+
+	for col := 0; col < first; col++ {
+		if columnHasValues(data, region.headerRow, col) {
+			region.firstCol = first
+			break
+		}
+	}
+
+	widest := 0
+	for _, row := range data[region.headerRow:] {
+		widest = max(widest, len(row))
+	}
+	for col := last + 1; col < widest; col++ {
+		if columnHasValues(data, region.headerRow, col) {
+			region.lastCol = last
+			break
+		}
+	}
+
+	// a single column table can not be told apart from the notes below it
+	if first == last {
+		return region
+	}
+
+	for index := region.headerRow + 1; index < len(data); index++ {
+		if nonEmptyCellsBetween(data[index], first, last) > 0 {
+			continue
+		}
+
+		notes := 0
+		for _, row := range data[index+1:] {
+			cells := nonEmptyCells(row)
+			if cells > 1 {
+				notes = -1
+				break
+			}
+			notes += cells
+		}
+		if notes > 0 {
+			region.lastRow = index - 1
+		}
+		if notes != -1 {
+			break
+		}
+	}
+
+	return region
+}
+
+// uses the region given by the user or detects one when none was given
+func resolveRegion(data [][]string, region *sheetRegion) sheetRegion {
+	if region != nil {
+		return *region
+	}
+	return detectRegion(data)
+}
+
+// cuts the region out of the sheet data. The header row is the first row of the result
+func extractRegion(data [][]string, region sheetRegion) [][]string {
+	var regionData [][]string
+
+	for index, row := range data {
+		if index < region.headerRow || (region.lastRow >= 0 && index > region.lastRow) {
+			continue
+		}
+
+		lastCol := len(row) - 1
+		if region.lastCol >= 0 {
+			lastCol = region.lastCol
+		}
+
+		var regionRow []string
+		for col := region.firstCol; col <= lastCol; col++ {
+			regionRow = append(regionRow, cellAt(row, col))
+		}
+		regionData = append(regionData, regionRow)
+	}
+
+	return regionData
+}
+
+func regionIsWholeSheet(region sheetRegion) bool {
+	return region.headerRow == 0 && region.lastRow < 0 && region.firstCol == 0 && region.lastCol < 0
+}
+
+func regionString(region sheetRegion) string {
+	regionString := "header row " + strconv.Itoa(region.headerRow+1)
+
+	if region.lastRow >= 0 {
+		regionString += ", data to row " + strconv.Itoa(region.lastRow+1)
+	}
+
+	if region.lastCol >= 0 {
+		regionString += ", columns " + columnLetter(region.firstCol) + "-" + columnLetter(region.lastCol)
+	} else if region.firstCol > 0 {
+		regionString += ", columns from " + columnLetter(region.firstCol)
+	}
+
+	return regionString
+}
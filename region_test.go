@@ -0,0 +1,157 @@
+package main
+
+import "testing"
+
+func TestParseRegion(t *testing.T) {
+	tests := []struct {
+		spec   string
+		region sheetRegion
+		err    bool
+	}{
+		{spec: "5", region: sheetRegion{headerRow: 4, lastRow: -1, firstCol: 0, lastCol: -1}},
+		{spec: "5:100", region: sheetRegion{headerRow: 4, lastRow: 99, firstCol: 0, lastCol: -1}},
+		{spec: "B5", region: sheetRegion{headerRow: 4, lastRow: -1, firstCol: 1, lastCol: -1}},
+		{spec: "b5:f100", region: sheetRegion{headerRow: 4, lastRow: 99, firstCol: 1, lastCol: 5}},
+		{spec: "5:F100", region: sheetRegion{headerRow: 4, lastRow: 99, firstCol: 0, lastCol: 5}},
+		{spec: "B5:100", region: sheetRegion{headerRow: 4, lastRow: 99, firstCol: 1, lastCol: -1}},
+		{spec: " 3 ", region: sheetRegion{headerRow: 2, lastRow: -1, firstCol: 0, lastCol: -1}},
+		{spec: "0", err: true},
+		{spec: "10:5", err: true},
+		{spec: "F5:B100", err: true},
+		{spec: "B5:", err: true},
+		{spec: "5B", err: true},
+		{spec: "", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			region, err := parseRegion(test.spec)
+			if test.err {
+				if err == nil {
+					t.Errorf("got %+v, want an error", region)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if region != test.region {
+				t.Errorf("got %+v, want %+v", region, test.region)
+			}
+		})
+	}
+}
+
+func TestDetectHeaderRow(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   [][]string
+		header int
+	}{
+		{name: "empty sheet", data: nil, header: 0},
+		{name: "header in the first row", data: [][]string{{"ID", "Name"}, {"1", "a"}}, header: 0},
+		{name: "title and blank rows", data: [][]string{{"Report"}, {}, {"", "  "}, {"ID", "Name"}, {"1", "a"}}, header: 3},
+		{name: "title in another column", data: [][]string{{"", "", "Report"}, {"Notes"}, {"ID", "Name"}}, header: 2},
+		{name: "single column table", data: [][]string{{}, {"ID"}, {"1"}}, header: 1},
+		{name: "only blank rows", data: [][]string{{}, {""}}, header: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if header := detectHeaderRow(test.data); header != test.header {
+				t.Errorf("got row %d, want row %d", header, test.header)
+			}
+		})
+	}
+}
+
+func TestDetectRegion(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   [][]string
+		region sheetRegion
+	}{
+		{
+			name:   "plain table",
+			data:   [][]string{{"ID", "Name"}, {"1", "a"}, {"2", "b"}},
+			region: sheetRegion{headerRow: 0, lastRow: -1, firstCol: 0, lastCol: -1},
+		},
+		{
+			name:   "title block",
+			data:   [][]string{{"Report"}, {}, {"ID", "Name"}, {"1", "a"}},
+			region: sheetRegion{headerRow: 2, lastRow: -1, firstCol: 0, lastCol: -1},
+		},
+		{
+			name:   "notes below the table",
+			data:   [][]string{{"ID", "Name"}, {"1", "a"}, {"2", "b"}, {}, {"Checked by Sam"}, {"", "Draft"}},
+			region: sheetRegion{headerRow: 0, lastRow: 2, firstCol: 0, lastCol: -1},
+		},
+		{
+			name:   "blank row inside the table",
+			data:   [][]string{{"ID", "Name"}, {"1", "a"}, {}, {"2", "b"}},
+			region: sheetRegion{headerRow: 0, lastRow: -1, firstCol: 0, lastCol: -1},
+		},
+		{
+			name:   "trailing blank rows",
+			data:   [][]string{{"ID", "Name"}, {"1", "a"}, {}, {}},
+			region: sheetRegion{headerRow: 0, lastRow: -1, firstCol: 0, lastCol: -1},
+		},
+		{
+			name:   "table starting in column C",
+			data:   [][]string{{"", "", "ID", "Name"}, {"", "", "1", "a"}},
+			region: sheetRegion{headerRow: 0, lastRow: -1, firstCol: 0, lastCol: -1},
+		},
+		{
+			name:   "side table after a gap",
+			data:   [][]string{{"", "ID", "Name", "", "Totals"}, {"", "1", "a", "", "3"}, {"Note", "2", "b"}},
+			region: sheetRegion{headerRow: 0, lastRow: -1, firstCol: 0, lastCol: 2},
+		},
+		{
+			name:   "label column before a gap",
+			data:   [][]string{{"Title"}, {"", "", "ID", "Name"}, {"x", "", "1", "a"}},
+			region: sheetRegion{headerRow: 1, lastRow: -1, firstCol: 2, lastCol: -1},
+		},
+		{
+			name:   "data in a column without a header",
+			data:   [][]string{{"ID", "Name"}, {"1", "a", "extra"}},
+			region: sheetRegion{headerRow: 0, lastRow: -1, firstCol: 0, lastCol: -1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if region := detectRegion(test.data); region != test.region {
+				t.Errorf("got %+v, want %+v", region, test.region)
+			}
+		})
+	}
+}
+
+func TestRegionsFor(t *testing.T) {
+	config := regionConfig{Workbooks: []workbookRegions{
+		{Pattern: "*.xlsx", Regions: map[string]string{"*": "3"}},
+		{Pattern: "reports/budget.xlsx", Regions: map[string]string{"Summary": "B5:F100"}},
+	}}
+	flags := regionFlags{"Summary": {headerRow: 1, lastRow: -1, lastCol: -1}}
+
+	regions, err := config.regionsFor("reports/budget.xlsx", regionFlags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions) != 2 || regions["*"].headerRow != 2 || regions["Summary"].lastCol != 5 {
+		t.Errorf("got %v", regions)
+	}
+
+	regions, err = config.regionsFor("other/budget.xlsx", flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions) != 2 || regions["*"].headerRow != 2 || regions["Summary"].headerRow != 1 {
+		t.Errorf("flags should override the config, got %v", regions)
+	}
+
+	config.Workbooks[0].Regions["*"] = "0"
+	if _, err := config.regionsFor("budget.xlsx", flags); err == nil {
+		t.Errorf("want an error for an invalid region")
+	}
+}
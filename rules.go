@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	dataValidationRule    = "Data validation"
+	conditionalFormatRule = "Conditional format"
+	mergedCellsRule       = "Merged cells"
+)
+
+// a data validation, conditional format or merged range of a sheet
+type sheetRule struct {
+	kind        string
+	ranges      string
+	description string
+}
+
+type ruleChange struct {
+	changeType   differenceType
+	kind         string
+	theirsRanges string
+	mineRanges   string
+	theirs       string
+	mine         string
+}
+
+// reads the data validations, conditional formats and merged cells of the
+// sheet. Returns nil if the sheet does not exist in the workbook
+func readSheetRules(excelFile *excelize.File, sheet string) []sheetRule {
+	if excelFile == nil {
+		return nil
+	}
+
+	if index, err := excelFile.GetSheetIndex(sheet); err != nil || index < 0 {
+		return nil
+	}
+
+	var rules []sheetRule
+
+	validations, err := excelFile.GetDataValidations(sheet)
+	if err != nil {
+		panic(err)
+	}
+	for _, validation := range validations {
+		rules = addSheetRule(rules, dataValidationRule, validation.Sqref, dataValidationDescription(validation))
+	}
+
+	conditionalFormats, err := excelFile.GetConditionalFormats(sheet)
+	if err != nil {
+		panic(err)
+	}
+	var formatRanges []string
+	for ranges := range conditionalFormats {
+		formatRanges = append(formatRanges, ranges)
+	}
+	sort.Strings(formatRanges)
+
+	for _, ranges := range formatRanges {
+		for _, format := range conditionalFormats[ranges] {
+			rules = addSheetRule(rules, conditionalFormatRule, ranges, conditionalFormatDescription(excelFile, format))
+		}
+	}
+
+	mergedCells, err := excelFile.GetMergeCells(sheet)
+	if err != nil {
+		panic(err)
+	}
+	for _, merged := range mergedCells {
+		rules = addSheetRule(rules, mergedCellsRule, merged.GetStartAxis()+":"+merged.GetEndAxis(), "merged")
+	}
+
+	return rules
+}
+
+// rules of the same kind on the same range are combined into one rule
+func addSheetRule(rules []sheetRule, kind string, ranges string, description string) []sheetRule {
+	for index, rule := range rules {
+		if rule.kind == kind && rule.ranges == ranges {
+			rules[index].description += " / " + description
+			return rules
+		}
+	}
+
+	return append(rules, sheetRule{kind: kind, ranges: ranges, description: description})
+}
+
+func dataValidationDescription(validation *excelize.DataValidation) string {
+	var parts []string
+
+	parts = append(parts, validation.Type)
+	if validation.Operator != "" {
+		parts = append(parts, validation.Operator)
+	}
+	if validation.Formula1 != "" {
+		parts = append(parts, validation.Formula1)
+	}
+	if validation.Formula2 != "" {
+		parts = append(parts, "and "+validation.Formula2)
+	}
+	if validation.AllowBlank {
+		parts = append(parts, "allow blank")
+	}
+	if validation.ShowErrorMessage && validation.Error != nil {
+		parts = append(parts, "error \""+*validation.Error+"\"")
+	}
+	if validation.ShowInputMessage && validation.Prompt != nil {
+		parts = append(parts, "prompt \""+*validation.Prompt+"\"")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func conditionalFormatDescription(excelFile *excelize.File, format excelize.ConditionalFormatOptions) string {
+	var parts []string
+
+	parts = append(parts, format.Type)
+
+	for _, part := range []string{format.Criteria, format.Value,
+		format.MinType, format.MinValue, format.MinColor,
+		format.MidType, format.MidValue, format.MidColor,
+		format.MaxType, format.MaxValue, format.MaxColor,
+		format.BarColor, format.IconStyle} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	if format.StopIfTrue {
+		parts = append(parts, "stop if true")
+	}
+
+	// the format is an index into the styles of the workbook so describe the style instead
+	if format.Type != "2_color_scale" && format.Type != "3_color_scale" && format.Type != "data_bar" && format.Type != "icon_set" {
+		if style, err := excelFile.GetConditionalStyle(format.Format); err == nil && style != nil {
+			parts = append(parts, "("+styleDescription(style)+")")
+		} else {
+			parts = append(parts, fmt.Sprintf("(format %d)", format.Format))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func ruleKey(rule sheetRule) string {
+	return rule.kind + "%@!#!@%" + rule.ranges
+}
+
+// lists the rules that were added, removed or changed. Rules are matched by
+// kind and range, then rules that only moved to a different range are paired up
+func compareSheetRules(theirsRules, mineRules []sheetRule) []ruleChange {
+	var changes []ruleChange
+
+	theirsByKey := make(map[string]sheetRule)
+	for _, rule := range theirsRules {
+		theirsByKey[ruleKey(rule)] = rule
+	}
+
+	mineByKey := make(map[string]sheetRule)
+	for _, rule := range mineRules {
+		mineByKey[ruleKey(rule)] = rule
+	}
+
+	var added []sheetRule
+	for _, rule := range mineRules {
+		theirsRule, ok := theirsByKey[ruleKey(rule)]
+		if !ok {
+			added = append(added, rule)
+		} else if theirsRule.description != rule.description {
+			changes = append(changes, ruleChange{changeType: difference, kind: rule.kind, theirsRanges: rule.ranges, mineRanges: rule.ranges, theirs: theirsRule.description, mine: rule.description})
+		}
+	}
+
+	var removed []sheetRule
+	for _, rule := range theirsRules {
+		if _, ok := mineByKey[ruleKey(rule)]; !ok {
+			removed = append(removed, rule)
+		}
+	}
+
+	for _, theirsRule := range removed {
+		paired := false
+		for index, mineRule := range added {
+			if mineRule.kind == theirsRule.kind && mineRule.description == theirsRule.description && theirsRule.kind != mergedCellsRule {
+				changes = append(changes, ruleChange{changeType: difference, kind: theirsRule.kind, theirsRanges: theirsRule.ranges, mineRanges: mineRule.ranges, theirs: theirsRule.description, mine: mineRule.description})
+				added = append(added[:index], added[index+1:]...)
+				paired = true
+				break
+			}
+		}
+
+		if !paired {
+			changes = append(changes, ruleChange{changeType: deletion, kind: theirsRule.kind, theirsRanges: theirsRule.ranges, theirs: theirsRule.description})
+		}
+	}
+
+	for _, rule := range added {
+		changes = append(changes, ruleChange{changeType: addition, kind: rule.kind, mineRanges: rule.ranges, mine: rule.description})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].kind < changes[j].kind
+	})
+
+	return changes
+}
+
+func ruleChangeString(change ruleChange) string {
+	switch change.changeType {
+	case addition:
+		return change.kind + " added"
+	case deletion:
+		return change.kind + " removed"
+	default:
+		return change.kind + " changed"
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+type editOperation int
+
+const (
+	editEqual editOperation = iota
+	editDelete
+	editInsert
+)
+
+type edit struct {
+	operation editOperation
+	theirsPos int
+	minePos   int
+}
+
+// a run of consecutive changed rows. Start positions are indexes into the
+// theirs and mine data where the hunk begins
+type diffHunk struct {
+	theirsStart int
+	theirsCount int
+	mineStart   int
+	mineCount   int
+	lines       []differenceLine
+}
+
+func rowToString(row []string) string {
+	return strings.Join(row, "%@!#!@%")
+}
+
+// finds the shortest edit script between theirs and mine using the Myers diff
+// algorithm. Rows are compared as whole rows so repeated rows are kept in order
+func myersDiff(theirs []string, mine []string) []edit {
+	n := len(theirs)
+	m := len(mine)
+	maxSteps := n + m
+	offset := maxSteps + 1
+
+	v := make([]int, 2*maxSteps+3)
+	var trace [][]int
+
+	for d := 0; d <= maxSteps; d++ {
+		// only keep the diagonals that can be reached in d steps
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+
+			for x < n && y < m && theirs[x] == mine[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrackEdits(trace, n, m)
+			}
+		}
+	}
+
+	return nil
+}
+
+func backtrackEdits(trace [][]int, n int, m int) []edit {
+	var edits []edit
+
+	x := n
+	y := m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		// trace[d] starts at diagonal -d-1
+		var prevK int
+		if k == -d || (k != d && v[k-1+d+1] < v[k+1+d+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[prevK+d+1]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{operation: editEqual, theirsPos: x - 1, minePos: y - 1})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{operation: editInsert, theirsPos: x, minePos: y - 1})
+			} else {
+				edits = append(edits, edit{operation: editDelete, theirsPos: x - 1, minePos: y})
+			}
+		}
+
+		x = prevX
+		y = prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+
+	return edits
+}
+
+// groups the edit script into hunks. Deleted rows are paired with the most
+// similar inserted row in the same hunk and reported as changed rows. Rows are
+// only equal when their cell details are equal as well
+func sequenceDiffHunks(dataTheirs [][]string, dataMine [][]string, theirsRowDetails []string, mineRowDetails []string) []diffHunk {
+	var theirsStrings []string
+	for index, row := range dataTheirs {
+		theirsStrings = append(theirsStrings, rowToString(row)+"%@!#!@%"+cellAt(theirsRowDetails, index))
+	}
+
+	var mineStrings []string
+	for index, row := range dataMine {
+		mineStrings = append(mineStrings, rowToString(row)+"%@!#!@%"+cellAt(mineRowDetails, index))
+	}
+
+	var hunks []diffHunk
+	var deleted []int
+	var inserted []int
+	linePos := 0
+	hunk := diffHunk{}
+
+	addLine := func(lineType differenceType, theirsPos int, minePos int) {
+		hunk.lines = append(hunk.lines, differenceLine{lineType: lineType, linePos: linePos, theirsPos: theirsPos, minePos: minePos})
+		linePos++
+	}
+
+	flushHunk := func() {
+		if len(deleted) == 0 && len(inserted) == 0 {
+			return
+		}
+
+		hunk.theirsCount = len(deleted)
+		hunk.mineCount = len(inserted)
+
+		nextInserted := 0
+		for _, theirsPos := range deleted {
+			best := -1
+			bestScore := 0
+			for index := nextInserted; index < len(inserted); index++ {
+				score := matchingCells(dataTheirs[theirsPos], dataMine[inserted[index]])
+				if score > bestScore {
+					best = index
+					bestScore = score
+				}
+			}
+
+			if best < 0 {
+				addLine(deletion, theirsPos, -1)
+				continue
+			}
+
+			for _, minePos := range inserted[nextInserted:best] {
+				addLine(addition, -1, minePos)
+			}
+			addLine(difference, theirsPos, inserted[best])
+			nextInserted = best + 1
+		}
+
+		for _, minePos := range inserted[nextInserted:] {
+			addLine(addition, -1, minePos)
+		}
+
+		hunks = append(hunks, hunk)
+		hunk = diffHunk{}
+		deleted = nil
+		inserted = nil
+	}
+
+	for _, e := range myersDiff(theirsStrings, mineStrings) {
+		if e.operation == editEqual {
+			flushHunk()
+			continue
+		}
+
+		if len(deleted) == 0 && len(inserted) == 0 {
+			hunk.theirsStart = e.theirsPos
+			hunk.mineStart = e.minePos
+		}
+
+		if e.operation == editDelete {
+			deleted = append(deleted, e.theirsPos)
+		} else {
+			inserted = append(inserted, e.minePos)
+		}
+	}
+	flushHunk()
+
+	return hunks
+}
+
+// number of non empty cells that are the same in both rows
+func matchingCells(theirRow []string, mineRow []string) int {
+	matches := 0
+
+	for index, cell := range theirRow {
+		if cell != "" && cell == cellAt(mineRow, index) {
+			matches++
+		}
+	}
+
+	return matches
+}
+
+func hunkRangeString(start int, count int) string {
+	if count == 0 && start == 0 {
+		return "no rows"
+	}
+	if count == 0 {
+		return "no rows, after row " + strconv.Itoa(start)
+	}
+	if count == 1 {
+		return "row " + strconv.Itoa(start+1)
+	}
+	return "rows " + strconv.Itoa(start+1) + "-" + strconv.Itoa(start+count)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMyersDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		theirs  []string
+		mine    []string
+		changes int // number of inserted and deleted rows in the shortest edit script
+	}{
+		{name: "both empty", theirs: nil, mine: nil, changes: 0},
+		{name: "theirs empty", theirs: nil, mine: []string{"a", "b"}, changes: 2},
+		{name: "mine empty", theirs: []string{"a", "b"}, mine: nil, changes: 2},
+		{name: "identical", theirs: []string{"a", "b", "c"}, mine: []string{"a", "b", "c"}, changes: 0},
+		{name: "insert", theirs: []string{"a", "c"}, mine: []string{"a", "b", "c"}, changes: 1},
+		{name: "delete", theirs: []string{"a", "b", "c"}, mine: []string{"a", "c"}, changes: 1},
+		{name: "duplicate rows", theirs: []string{"a", "a", "b", "a"}, mine: []string{"a", "b", "a", "a"}, changes: 2},
+		{name: "mixed", theirs: []string{"a", "b", "c", "a", "b", "b", "a"}, mine: []string{"c", "b", "a", "b", "a", "c"}, changes: 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			edits := myersDiff(test.theirs, test.mine)
+
+			changes := 0
+			var rebuilt []string
+			var theirsUsed []int
+			for _, e := range edits {
+				switch e.operation {
+				case editEqual:
+					if test.theirs[e.theirsPos] != test.mine[e.minePos] {
+						t.Fatalf("equal edit pairs %q with %q", test.theirs[e.theirsPos], test.mine[e.minePos])
+					}
+					rebuilt = append(rebuilt, test.theirs[e.theirsPos])
+					theirsUsed = append(theirsUsed, e.theirsPos)
+				case editDelete:
+					changes++
+					theirsUsed = append(theirsUsed, e.theirsPos)
+				case editInsert:
+					changes++
+					rebuilt = append(rebuilt, test.mine[e.minePos])
+				}
+			}
+
+			if changes != test.changes {
+				t.Errorf("got %d inserted and deleted rows, want %d", changes, test.changes)
+			}
+
+			if len(rebuilt) != len(test.mine) || (len(rebuilt) > 0 && !reflect.DeepEqual(rebuilt, test.mine)) {
+				t.Errorf("edits rebuild %q, want %q", rebuilt, test.mine)
+			}
+
+			// every theirs row is kept or deleted once and in order
+			for index, pos := range theirsUsed {
+				if pos != index {
+					t.Fatalf("theirs rows used out of order: %v", theirsUsed)
+				}
+			}
+			if len(theirsUsed) != len(test.theirs) {
+				t.Errorf("edits use %d theirs rows, want %d", len(theirsUsed), len(test.theirs))
+			}
+		})
+	}
+}
+
+func TestSequenceDiffHunks(t *testing.T) {
+	// a line of a hunk as its type and the rows it pairs
+	type hunkLine struct {
+		lineType  differenceType
+		theirsPos int
+		minePos   int
+	}
+	// a hunk as the ranges shown in its header and its lines
+	type hunk struct {
+		theirs string
+		mine   string
+		lines  []hunkLine
+	}
+
+	tests := []struct {
+		name          string
+		theirs        [][]string
+		mine          [][]string
+		theirsDetails []string
+		mineDetails   []string
+		hunks         []hunk
+	}{
+		{
+			name:   "identical",
+			theirs: [][]string{{"ID", "Name"}, {"1", "a"}},
+			mine:   [][]string{{"ID", "Name"}, {"1", "a"}},
+		},
+		{
+			// the changed row is paired with the inserted row it matches best
+			name:   "changed row between inserts and deletes",
+			theirs: [][]string{{"ID", "Name", "Qty"}, {"1", "a", "5"}, {"2", "b", "6"}, {"3", "c", "7"}, {"4", "d", "8"}},
+			mine:   [][]string{{"ID", "Name", "Qty"}, {"9", "q", "0"}, {"2", "b", "60"}, {"8", "p", "1"}, {"4", "d", "8"}},
+			hunks: []hunk{{theirs: "rows 2-4", mine: "rows 2-4", lines: []hunkLine{
+				{deletion, 1, -1}, {addition, -1, 1}, {difference, 2, 2}, {deletion, 3, -1}, {addition, -1, 3},
+			}}},
+		},
+		{
+			// the inserted rows before the pair are added and later rows can't pair with them
+			name:   "rows paired in order",
+			theirs: [][]string{{"ID", "Name"}, {"1", "a"}, {"2", "b"}},
+			mine:   [][]string{{"ID", "Name"}, {"2", "bb"}, {"1", "aa"}},
+			hunks: []hunk{{theirs: "rows 2-3", mine: "rows 2-3", lines: []hunkLine{
+				{addition, -1, 1}, {difference, 1, 2}, {deletion, 2, -1},
+			}}},
+		},
+		{
+			name:   "separate hunks",
+			theirs: [][]string{{"a"}, {"b"}, {"c"}, {"d"}, {"e"}},
+			mine:   [][]string{{"a"}, {"x"}, {"c"}, {"d"}, {"e"}, {"f"}},
+			hunks: []hunk{
+				{theirs: "row 2", mine: "row 2", lines: []hunkLine{{deletion, 1, -1}, {addition, -1, 1}}},
+				{theirs: "no rows, after row 5", mine: "row 6", lines: []hunkLine{{addition, -1, 5}}},
+			},
+		},
+		{
+			name:   "rows removed from the start",
+			theirs: [][]string{{"a"}, {"b"}, {"c"}},
+			mine:   [][]string{{"c"}},
+			hunks:  []hunk{{theirs: "rows 1-2", mine: "no rows", lines: []hunkLine{{deletion, 0, -1}, {deletion, 1, -1}}}},
+		},
+		{
+			// rows with the same values are different when their details are
+			name:          "details changed",
+			theirs:        [][]string{{"ID"}, {"1"}},
+			mine:          [][]string{{"ID"}, {"1"}},
+			theirsDetails: []string{"", "bold"},
+			mineDetails:   []string{"", "italic"},
+			hunks:         []hunk{{theirs: "row 2", mine: "row 2", lines: []hunkLine{{difference, 1, 1}}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var hunks []hunk
+			for _, diffHunk := range sequenceDiffHunks(test.theirs, test.mine, test.theirsDetails, test.mineDetails) {
+				got := hunk{theirs: hunkRangeString(diffHunk.theirsStart, diffHunk.theirsCount), mine: hunkRangeString(diffHunk.mineStart, diffHunk.mineCount)}
+				for _, line := range diffHunk.lines {
+					got.lines = append(got.lines, hunkLine{line.lineType, line.theirsPos, line.minePos})
+				}
+				hunks = append(hunks, got)
+			}
+
+			if !reflect.DeepEqual(hunks, test.hunks) {
+				t.Errorf("hunks %+v, want %+v", hunks, test.hunks)
+			}
+		})
+	}
+}
+
+func TestHunkRangeString(t *testing.T) {
+	tests := []struct {
+		start int
+		count int
+		want  string
+	}{
+		{start: 0, count: 0, want: "no rows"},
+		{start: 3, count: 0, want: "no rows, after row 3"},
+		{start: 0, count: 1, want: "row 1"},
+		{start: 4, count: 1, want: "row 5"},
+		{start: 1, count: 3, want: "rows 2-4"},
+	}
+
+	for _, test := range tests {
+		if got := hunkRangeString(test.start, test.count); got != test.want {
+			t.Errorf("hunkRangeString(%d, %d) = %q, want %q", test.start, test.count, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type sheetChangeType int
+
+const (
+	sheetAdded sheetChangeType = iota
+	sheetRemoved
+	sheetRenamed
+	sheetMoved
+	sheetVisibilityChanged
+)
+
+// sheets with at least this much of their rows in common are treated as renamed
+const renameSimilarity = 0.5
+
+type sheetChange struct {
+	changeType  sheetChangeType
+	theirsName  string
+	mineName    string
+	theirsPos   int // position of the sheet in theirs, -1 if it is not in theirs
+	minePos     int // position of the sheet in mine, -1 if it is not in mine
+	theirsState string
+	mineState   string
+	similarity  float64
+}
+
+// a sheet in theirs and the sheet in mine it is compared against. One of the
+// names is blank when the sheet was added or removed
+type sheetPair struct {
+	theirs string
+	mine   string
+}
+
+type workbookStructure struct {
+	pairs   []sheetPair
+	changes []sheetChange
+}
+
+// returns the visibility of every sheet in the workbook: visible, hidden or veryHidden
+func sheetStates(excelFile *excelize.File) map[string]string {
+	states := make(map[string]string)
+
+	// GetSheetList makes sure the workbook has been read
+	for _, sheet := range excelFile.GetSheetList() {
+		states[sheet] = "visible"
+	}
+
+	for _, sheet := range excelFile.WorkBook.Sheets.Sheet {
+		if sheet.State != "" {
+			states[sheet.Name] = sheet.State
+		}
+	}
+
+	return states
+}
+
+// the share of rows the two sheets have in common, from 0 to 1
+func sheetSimilarity(theirsRows, mineRows [][]string) float64 {
+	if len(theirsRows) == 0 || len(mineRows) == 0 {
+		return 0
+	}
+
+	rowCounts := make(map[string]int)
+	for _, row := range theirsRows {
+		rowCounts[rowToString(row)]++
+	}
+
+	common := 0
+	for _, row := range mineRows {
+		key := rowToString(row)
+		if rowCounts[key] > 0 {
+			rowCounts[key]--
+			common++
+		}
+	}
+
+	return float64(2*common) / float64(len(theirsRows)+len(mineRows))
+}
+
+func readSheetRows(excelFile *excelize.File, sheet string) [][]string {
+	rows, err := excelFile.GetRows(sheet)
+	if err != nil {
+		panic(err)
+	}
+	return rows
+}
+
+// works out which sheets were added, removed, renamed, moved or had their
+// visibility changed and which sheets should be compared with each other
+func compareWorkbookStructure(excelTheirs, excelMine *excelize.File) workbookStructure {
+	var structure workbookStructure
+
+	theirsSheets := excelTheirs.GetSheetList()
+	mineSheets := excelMine.GetSheetList()
+	theirsStates := sheetStates(excelTheirs)
+	mineStates := sheetStates(excelMine)
+
+	var removed []string
+	for _, sheet := range theirsSheets {
+		if !containsString(mineSheets, sheet) {
+			removed = append(removed, sheet)
+		}
+	}
+
+	var added []string
+	for _, sheet := range mineSheets {
+		if !containsString(theirsSheets, sheet) {
+			added = append(added, sheet)
+		}
+	}
+
+	// pair up removed and added sheets that share most of their rows as renames
+	type renameCandidate struct {
+		theirs     string
+		mine       string
+		similarity float64
+	}
+
+	var candidates []renameCandidate
+	for _, theirsSheet := range removed {
+		theirsRows := readSheetRows(excelTheirs, theirsSheet)
+		for _, mineSheet := range added {
+			similarity := sheetSimilarity(theirsRows, readSheetRows(excelMine, mineSheet))
+			if verboseOutput {
+				fmt.Printf("Sheet %s and %s similarity: %.2f\n", theirsSheet, mineSheet, similarity)
+			}
+			if similarity >= renameSimilarity {
+				candidates = append(candidates, renameCandidate{theirs: theirsSheet, mine: mineSheet, similarity: similarity})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].similarity > candidates[j].similarity
+	})
+
+	renamedTo := make(map[string]string)
+	renamedFrom := make(map[string]string)
+	for _, candidate := range candidates {
+		if renamedTo[candidate.theirs] != "" || renamedFrom[candidate.mine] != "" {
+			continue
+		}
+		renamedTo[candidate.theirs] = candidate.mine
+		renamedFrom[candidate.mine] = candidate.theirs
+		structure.changes = append(structure.changes, sheetChange{changeType: sheetRenamed, theirsName: candidate.theirs, mineName: candidate.mine,
+			theirsPos: slices.Index(theirsSheets, candidate.theirs), minePos: slices.Index(mineSheets, candidate.mine), similarity: candidate.similarity})
+	}
+
+	for _, sheet := range removed {
+		if renamedTo[sheet] == "" {
+			structure.changes = append(structure.changes, sheetChange{changeType: sheetRemoved, theirsName: sheet, theirsPos: slices.Index(theirsSheets, sheet), minePos: -1})
+		}
+	}
+
+	for _, sheet := range added {
+		if renamedFrom[sheet] == "" {
+			structure.changes = append(structure.changes, sheetChange{changeType: sheetAdded, mineName: sheet, theirsPos: -1, minePos: slices.Index(mineSheets, sheet)})
+		}
+	}
+
+	// compare the order of the sheets that are in both workbooks
+	var theirsOrder []string
+	for _, sheet := range theirsSheets {
+		if renamedTo[sheet] != "" {
+			theirsOrder = append(theirsOrder, renamedTo[sheet])
+		} else if containsString(mineSheets, sheet) {
+			theirsOrder = append(theirsOrder, sheet)
+		}
+	}
+
+	var mineOrder []string
+	for _, sheet := range mineSheets {
+		if renamedFrom[sheet] != "" || containsString(theirsSheets, sheet) {
+			mineOrder = append(mineOrder, sheet)
+		}
+	}
+
+	for _, e := range myersDiff(theirsOrder, mineOrder) {
+		if e.operation != editInsert {
+			continue
+		}
+		mineSheet := mineOrder[e.minePos]
+		theirsSheet := mineSheet
+		if renamedFrom[mineSheet] != "" {
+			theirsSheet = renamedFrom[mineSheet]
+		}
+		structure.changes = append(structure.changes, sheetChange{changeType: sheetMoved, theirsName: theirsSheet, mineName: mineSheet,
+			theirsPos: slices.Index(theirsSheets, theirsSheet), minePos: slices.Index(mineSheets, mineSheet)})
+	}
+
+	for _, mineSheet := range mineOrder {
+		theirsSheet := mineSheet
+		if renamedFrom[mineSheet] != "" {
+			theirsSheet = renamedFrom[mineSheet]
+		}
+
+		structure.pairs = append(structure.pairs, sheetPair{theirs: theirsSheet, mine: mineSheet})
+
+		if theirsStates[theirsSheet] != mineStates[mineSheet] {
+			structure.changes = append(structure.changes, sheetChange{changeType: sheetVisibilityChanged, theirsName: theirsSheet, mineName: mineSheet,
+				theirsPos: slices.Index(theirsSheets, theirsSheet), minePos: slices.Index(mineSheets, mineSheet),
+				theirsState: theirsStates[theirsSheet], mineState: mineStates[mineSheet]})
+		}
+	}
+
+	// added sheets keep their place in mine, removed sheets go at the end
+	for _, sheet := range mineSheets {
+		if !containsString(mineOrder, sheet) {
+			index := slices.Index(mineSheets, sheet)
+			structure.pairs = slices.Insert(structure.pairs, min(index, len(structure.pairs)), sheetPair{mine: sheet})
+		}
+	}
+
+	for _, sheet := range removed {
+		if renamedTo[sheet] == "" {
+			structure.pairs = append(structure.pairs, sheetPair{theirs: sheet})
+		}
+	}
+
+	return structure
+}
+
+func sheetChangeString(change sheetChange) string {
+	switch change.changeType {
+	case sheetAdded:
+		return "Sheet added"
+	case sheetRemoved:
+		return "Sheet removed"
+	case sheetRenamed:
+		return "Sheet renamed"
+	case sheetMoved:
+		return "Sheet moved"
+	default:
+		return "Visibility changed"
+	}
+}
+
+// the name of the sheet shown in the diff
+func sheetPairName(pair sheetPair) string {
+	if pair.theirs == "" {
+		return pair.mine
+	}
+	if pair.mine == "" || pair.mine == pair.theirs {
+		return pair.theirs
+	}
+	return pair.mine + " (renamed from " + pair.theirs + ")"
+}
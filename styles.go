@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// the built in number formats that show up the most in our workbooks
+var builtInNumberFormats = map[int]string{
+	0:  "General",
+	1:  "0",
+	2:  "0.00",
+	3:  "#,##0",
+	4:  "#,##0.00",
+	9:  "0%",
+	10: "0.00%",
+	11: "0.00E+00",
+	14: "m/d/yyyy",
+	22: "m/d/yyyy h:mm",
+	49: "@",
+}
+
+// reads a description of the style of every cell in the sheet. Style ids are
+// different in every workbook so the descriptions are compared instead
+func readSheetStyles(excelFile *excelize.File, sheet string) [][]string {
+	descriptions := make(map[int]string)
+
+	return readCellGrid(excelFile, sheet, func(cellName string) string {
+		styleID, err := excelFile.GetCellStyle(sheet, cellName)
+		if err != nil {
+			panic(err)
+		}
+
+		description, ok := descriptions[styleID]
+		if !ok {
+			style, err := excelFile.GetStyle(styleID)
+			if err != nil {
+				panic(err)
+			}
+			description = styleDescription(style)
+			descriptions[styleID] = description
+		}
+
+		return description
+	})
+}
+
+// the parts of each style description by part name, kept when the description
+// is built as a number format can have "; " in it
+var styleDescriptionParts = make(map[string]map[string]string)
+
+// describes a style as "; " separated parts that each start with the part name
+func styleDescription(style *excelize.Style) string {
+	var parts []string
+
+	if style.Font != nil {
+		var font []string
+		if style.Font.Family != "" {
+			font = append(font, style.Font.Family)
+		}
+		if style.Font.Size != 0 {
+			font = append(font, strconv.FormatFloat(style.Font.Size, 'f', -1, 64))
+		}
+		if style.Font.Bold {
+			font = append(font, "bold")
+		}
+		if style.Font.Italic {
+			font = append(font, "italic")
+		}
+		if style.Font.Strike {
+			font = append(font, "strikethrough")
+		}
+		if style.Font.Underline != "" {
+			font = append(font, "underline "+style.Font.Underline)
+		}
+		if style.Font.Color != "" {
+			font = append(font, "color "+style.Font.Color)
+		}
+		if style.Font.ColorTheme != nil {
+			font = append(font, fmt.Sprintf("theme color %d", *style.Font.ColorTheme))
+		}
+		if len(font) > 0 {
+			parts = append(parts, "font: "+strings.Join(font, " "))
+		}
+	}
+
+	if style.Fill.Type != "" && (style.Fill.Pattern != 0 || len(style.Fill.Color) > 0) {
+		parts = append(parts, fmt.Sprintf("fill: %s %d %s", style.Fill.Type, style.Fill.Pattern, strings.Join(style.Fill.Color, " ")))
+	}
+
+	var borders []string
+	for _, border := range style.Border {
+		if border.Style != 0 {
+			borders = append(borders, fmt.Sprintf("%s %d %s", border.Type, border.Style, border.Color))
+		}
+	}
+	if len(borders) > 0 {
+		parts = append(parts, "border: "+strings.Join(borders, ", "))
+	}
+
+	if style.Alignment != nil {
+		var alignment []string
+		if style.Alignment.Horizontal != "" {
+			alignment = append(alignment, style.Alignment.Horizontal)
+		}
+		if style.Alignment.Vertical != "" {
+			alignment = append(alignment, style.Alignment.Vertical)
+		}
+		if style.Alignment.WrapText {
+			alignment = append(alignment, "wrap")
+		}
+		if style.Alignment.Indent != 0 {
+			alignment = append(alignment, fmt.Sprintf("indent %d", style.Alignment.Indent))
+		}
+		if style.Alignment.TextRotation != 0 {
+			alignment = append(alignment, fmt.Sprintf("rotation %d", style.Alignment.TextRotation))
+		}
+		if len(alignment) > 0 {
+			parts = append(parts, "alignment: "+strings.Join(alignment, " "))
+		}
+	}
+
+	if style.CustomNumFmt != nil {
+		parts = append(parts, "number format: "+*style.CustomNumFmt)
+	} else if format, ok := builtInNumberFormats[style.NumFmt]; ok {
+		parts = append(parts, "number format: "+format)
+	} else {
+		parts = append(parts, fmt.Sprintf("number format: built in %d", style.NumFmt))
+	}
+
+	description := strings.Join(parts, "; ")
+	if _, ok := styleDescriptionParts[description]; !ok {
+		namedParts := make(map[string]string)
+		for _, part := range parts {
+			name, _, _ := strings.Cut(part, ": ")
+			namedParts[name] = part
+		}
+		styleDescriptionParts[description] = namedParts
+	}
+
+	return description
+}
+
+// returns only the parts of two style descriptions that are different
+func styleDifference(theirsStyle, mineStyle string) (string, string) {
+	theirsParts := styleDescriptionParts[theirsStyle]
+	mineParts := styleDescriptionParts[mineStyle]
+
+	var theirsDiff []string
+	var mineDiff []string
+
+	for _, name := range []string{"font", "fill", "border", "alignment", "number format"} {
+		if theirsParts[name] == mineParts[name] {
+			continue
+		}
+
+		if theirsParts[name] == "" {
+			theirsDiff = append(theirsDiff, name+": none")
+		} else {
+			theirsDiff = append(theirsDiff, theirsParts[name])
+		}
+
+		if mineParts[name] == "" {
+			mineDiff = append(mineDiff, name+": none")
+		} else {
+			mineDiff = append(mineDiff, mineParts[name])
+		}
+	}
+
+	return strings.Join(theirsDiff, "; "), strings.Join(mineDiff, "; ")
+}
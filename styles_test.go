@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestStyleDifference(t *testing.T) {
+	numberFormat := func(format string) *string { return &format }
+
+	tests := []struct {
+		name   string
+		theirs *excelize.Style
+		mine   *excelize.Style
+		old    string
+		new    string
+	}{
+		{
+			name:   "same style",
+			theirs: &excelize.Style{Font: &excelize.Font{Bold: true}},
+			mine:   &excelize.Style{Font: &excelize.Font{Bold: true}},
+		},
+		{
+			name:   "font changed",
+			theirs: &excelize.Style{Font: &excelize.Font{Bold: true}},
+			mine:   &excelize.Style{Font: &excelize.Font{Italic: true}},
+			old:    "font: bold",
+			new:    "font: italic",
+		},
+		{
+			name:   "fill added",
+			theirs: &excelize.Style{},
+			mine:   &excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFFF00"}}},
+			old:    "fill: none",
+			new:    "fill: pattern 1 FFFF00",
+		},
+		{
+			// the sections of a custom number format are separated by "; "
+			name:   "custom number format with sections",
+			theirs: &excelize.Style{Font: &excelize.Font{Bold: true}, CustomNumFmt: numberFormat("#,##0; (#,##0)")},
+			mine:   &excelize.Style{Font: &excelize.Font{Bold: true}, CustomNumFmt: numberFormat("#,##0; [Red](#,##0)")},
+			old:    "number format: #,##0; (#,##0)",
+			new:    "number format: #,##0; [Red](#,##0)",
+		},
+		{
+			name:   "font changed with a custom number format",
+			theirs: &excelize.Style{CustomNumFmt: numberFormat("0; -0; \"font: bold\"")},
+			mine:   &excelize.Style{Font: &excelize.Font{Bold: true}, CustomNumFmt: numberFormat("0; -0; \"font: bold\"")},
+			old:    "font: none",
+			new:    "font: bold",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			theirsStyle, mineStyle := styleDifference(styleDescription(test.theirs), styleDescription(test.mine))
+			if theirsStyle != test.old || mineStyle != test.new {
+				t.Errorf("got %q -> %q, want %q -> %q", theirsStyle, mineStyle, test.old, test.new)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+)
+
+// the number of unchanged rows shown around each change when -context is not given
+const defaultContextRows = 3
+
+// writes the lines of the text diff, colored when color is set
+type textDiff struct {
+	builder strings.Builder
+	color   bool
+}
+
+func (text *textDiff) line(color string, value string) {
+	if text.color && color != "" {
+		value = color + value + ansiReset
+	}
+	text.builder.WriteString(value + "\n")
+}
+
+// colors are only used when stdout is a terminal and NO_COLOR is not set
+func colorOutput(output *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := output.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// renders the diff like a unified diff. Changed rows list the cells that
+// changed and context is the number of unchanged rows shown around them
+func textWorkbookDiff(diff workbookDiff, context int, color bool) string {
+	text := textDiff{color: color}
+
+	if diff.header.theirs != "" || diff.header.mine != "" {
+		text.line(ansiBold, "--- "+diff.header.theirs)
+		text.line(ansiBold, "+++ "+diff.header.mine)
+	}
+	if diff.header.rename.from != "" {
+		text.line(ansiBold, "renamed from "+diff.header.rename.from+" to "+diff.header.rename.to)
+	}
+
+	for _, change := range diff.sheetChanges {
+		text.line(changeColor(change.changeType == sheetAdded, change.changeType == sheetRemoved), textSheetChange(change))
+	}
+
+	for _, change := range diff.definedNameChanges {
+		name := definedNameChangeName(change)
+		value := definedNameChangeString(change) + ": " + name.Name
+		if name.Scope != "" {
+			value += " (" + name.Scope + ")"
+		}
+		value += " " + textChangedValue(change.theirs.RefersTo, change.mine.RefersTo, change.changeType)
+		text.line(changeColor(change.changeType == addition, change.changeType == deletion), value)
+	}
+
+	for _, sheet := range diff.sheets {
+		text.line("", "")
+		textSheetDiff(&text, sheet, context)
+	}
+
+	return text.builder.String()
+}
+
+func textSheetDiff(text *textDiff, diff sheetDiff, context int) {
+	text.line(ansiBold, "== Sheet: "+diff.name+" ==")
+
+	if diff.smartCompare && len(diff.primaryKeys) > 0 {
+		text.line("", "Rows matched by primary key "+strings.Join(diff.primaryKeys, ", "))
+	} else if diff.fallbackReason != "" {
+		text.line("", "Rows compared in order: "+diff.fallbackReason)
+	}
+
+	if !regionIsWholeSheet(diff.theirsRegion) || !regionIsWholeSheet(diff.mineRegion) {
+		text.line("", "Theirs "+regionString(diff.theirsRegion)+", mine "+regionString(diff.mineRegion))
+	}
+
+	for _, change := range diff.columns.changes {
+		value := columnChangeString(change) + ": " + change.name
+		if change.changeType == columnRenamed {
+			value += " (was " + change.oldName + ")"
+		}
+		value += " " + textChangedValue(columnLetter(change.theirsCol), columnLetter(change.mineCol), difference)
+		text.line(changeColor(change.changeType == columnAdded, change.changeType == columnRemoved), value)
+	}
+
+	for _, change := range diff.commentChanges {
+		value := commentChangeString(change) + " " + commentCellString(change) + ": " + textChangedValue(commentString(change.theirs), commentString(change.mine), change.changeType)
+		text.line(changeColor(change.changeType == addition, change.changeType == deletion), value)
+	}
+
+	for _, change := range diff.ruleChanges {
+		value := ruleChangeString(change) + ": " + textChangedValue(change.theirsRanges, change.mineRanges, change.changeType) + " " + textChangedValue(change.theirs, change.mine, change.changeType)
+		text.line(changeColor(change.changeType == addition, change.changeType == deletion), value)
+	}
+
+	rows := diff.mergedRows()
+
+	// show the changed rows and the unchanged rows within context of them
+	shown := make([]bool, len(rows))
+	for index, row := range rows {
+		if row.lineType == unchanged {
+			continue
+		}
+		for near := max(0, index-context); near <= min(len(rows)-1, index+context); near++ {
+			shown[near] = true
+		}
+	}
+
+	changes := false
+	for start := 0; start < len(rows); start++ {
+		if !shown[start] {
+			continue
+		}
+		end := start
+		for end+1 < len(rows) && shown[end+1] {
+			end++
+		}
+
+		changes = true
+		text.line(ansiCyan, textHunkHeader(rows, start, end))
+		for _, row := range rows[start : end+1] {
+			textRow(text, diff, row)
+		}
+
+		start = end
+	}
+
+	if !changes {
+		text.line("", diff.equalMessage)
+	}
+}
+
+// a unified diff style header with the first row and the number of rows of
+// each side in the group
+func textHunkHeader(rows []differenceLine, start int, end int) string {
+	theirsStart, theirsCount, mineStart, mineCount := 0, 0, 0, 0
+
+	// groups with no rows on one side start at the row before them
+	for _, row := range rows[:start] {
+		if row.theirsRow > 0 {
+			theirsStart = row.theirsRow
+		}
+		if row.mineRow > 0 {
+			mineStart = row.mineRow
+		}
+	}
+
+	for _, row := range rows[start : end+1] {
+		if row.theirsRow > 0 {
+			if theirsCount == 0 {
+				theirsStart = row.theirsRow
+			}
+			theirsCount++
+		}
+		if row.mineRow > 0 {
+			if mineCount == 0 {
+				mineStart = row.mineRow
+			}
+			mineCount++
+		}
+	}
+
+	return "@@ -" + strconv.Itoa(theirsStart) + "," + strconv.Itoa(theirsCount) + " +" + strconv.Itoa(mineStart) + "," + strconv.Itoa(mineCount) + " @@"
+}
+
+func textRow(text *textDiff, diff sheetDiff, row differenceLine) {
+	label := textRowLabel(diff, row)
+
+	switch row.lineType {
+	case unchanged:
+		text.line("", "  "+label+": "+textRowValues(diff.dataMine, row.minePos))
+	case addition:
+		text.line(ansiGreen, "+ "+label+": "+textRowValues(diff.dataMine, row.minePos))
+	case deletion:
+		text.line(ansiRed, "- "+label+": "+textRowValues(diff.dataTheirs, row.theirsPos))
+	default:
+		text.line(ansiYellow, "~ "+label)
+		for _, cell := range diff.cellChanges(row) {
+			reference := cell.mineCell
+			if reference == "" {
+				reference = cell.theirsCell
+			}
+
+			theirs := textCellValue(cell.theirs, cell.theirsFormula)
+			mine := textCellValue(cell.mine, cell.mineFormula)
+			if theirs != mine {
+				text.line("", "    "+reference+": "+theirs+" -> "+mine)
+			}
+			if cell.theirsStyle != "" || cell.mineStyle != "" {
+				text.line("", "    "+reference+" style: "+cell.theirsStyle+" -> "+cell.mineStyle)
+			}
+		}
+	}
+}
+
+// the row number on each side and the primary key of the row
+func textRowLabel(diff sheetDiff, row differenceLine) string {
+	var label string
+	switch {
+	case row.mineRow == 0:
+		label = "row " + strconv.Itoa(row.theirsRow)
+	case row.lineType != unchanged && row.theirsRow != 0 && row.theirsRow != row.mineRow:
+		label = "row " + strconv.Itoa(row.theirsRow) + " -> " + strconv.Itoa(row.mineRow)
+	default:
+		label = "row " + strconv.Itoa(row.mineRow)
+	}
+
+	if row.lineType == unchanged {
+		return label
+	}
+
+	var key []string
+	for _, value := range diff.lineKey(row) {
+		key = append(key, value.column+"="+textconvValue(value.value))
+	}
+	if len(key) > 0 {
+		label += " (" + strings.Join(key, ", ") + ")"
+	}
+
+	return label
+}
+
+func textRowValues(data [][]string, pos int) string {
+	var values []string
+	if pos >= 0 && pos < len(data) {
+		for _, value := range data[pos] {
+			values = append(values, textconvValue(value))
+		}
+	}
+	return strings.Join(values, " | ")
+}
+
+func textCellValue(value string, formula string) string {
+	if value == "" && formula == "" {
+		return "(blank)"
+	}
+	value = textconvValue(value)
+	if formula != "" {
+		value += " [" + textconvValue(formula) + "]"
+	}
+	return value
+}
+
+// the old value, the new value or both depending on the change
+func textChangedValue(theirs string, mine string, changeType differenceType) string {
+	switch {
+	case changeType == addition || theirs == mine:
+		return textconvValue(mine)
+	case changeType == deletion:
+		return textconvValue(theirs)
+	default:
+		return textconvValue(theirs) + " -> " + textconvValue(mine)
+	}
+}
+
+func textSheetChange(change sheetChange) string {
+	switch change.changeType {
+	case sheetAdded:
+		return sheetChangeString(change) + ": " + change.mineName
+	case sheetRemoved:
+		return sheetChangeString(change) + ": " + change.theirsName
+	case sheetRenamed:
+		return sheetChangeString(change) + ": " + change.theirsName + " -> " + change.mineName
+	case sheetMoved:
+		return sheetChangeString(change) + ": " + change.mineName + " (position " + sheetPositionString(change.theirsPos) + " -> " + sheetPositionString(change.minePos) + ")"
+	default:
+		return sheetChangeString(change) + ": " + change.mineName + " (" + change.theirsState + " -> " + change.mineState + ")"
+	}
+}
+
+func changeColor(added bool, removed bool) string {
+	switch {
+	case added:
+		return ansiGreen
+	case removed:
+		return ansiRed
+	default:
+		return ansiYellow
+	}
+}
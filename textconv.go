@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func textconvUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: ged textconv [arguments] <excel workbook>\n")
+}
+
+// ged textconv prints a plain text version of the workbook for git diff and git log -p
+func runTextconv(args []string) {
+	flags := flag.NewFlagSet("textconv", flag.ExitOnError)
+	var formulaFlag = flags.Bool("f", false, "Include cell formulas")
+	flags.Usage = func() {
+		textconvUsage()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	var err error
+
+	// git gives textconv the pointer of workbooks stored in git lfs
+	workBook := flags.Arg(0)
+	if oid, _, pointerErr := readLFSPointer(workBook); pointerErr == nil && oid != "" {
+		workBook, err = resolveLFSPointerCopy("", workBook)
+		if err == nil {
+			err = writeWorkbookFileText(os.Stdout, workBook, *formulaFlag)
+			os.Remove(workBook)
+		}
+	} else {
+		err = writeWorkbookFileText(os.Stdout, workBook, *formulaFlag)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Unable to open %s: %s\n", flags.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+// writes the text of the workbook at path to the output
+func writeWorkbookFileText(output io.Writer, path string, formulas bool) error {
+	excelFile, err := openWorkbook(path)
+	if err != nil {
+		return err
+	}
+	defer excelFile.Close()
+
+	writer := bufio.NewWriter(output)
+	writeWorkbookText(writer, excelFile, formulas)
+	return writer.Flush()
+}
+
+// writes every non empty cell of every sheet on its own line as
+// "<cell>: <value>" under a header line for each sheet
+func writeWorkbookText(output io.Writer, excelFile *excelize.File, formulas bool) {
+	states := sheetStates(excelFile)
+
+	for _, sheet := range excelFile.GetSheetList() {
+		if states[sheet] == "visible" {
+			fmt.Fprintf(output, "== Sheet: %s ==\n", sheet)
+		} else {
+			fmt.Fprintf(output, "== Sheet: %s (%s) ==\n", sheet, states[sheet])
+		}
+
+		rows := readPaddedRows(excelFile, sheet)
+
+		var sheetFormulas [][]string
+		if formulas {
+			sheetFormulas = readSheetFormulas(excelFile, sheet)
+		}
+
+		for rowIndex, row := range rows {
+			for colIndex, value := range row {
+				formula := detailAt(sheetFormulas, rowIndex, colIndex)
+				if value == "" && formula == "" {
+					continue
+				}
+
+				cellName, err := excelize.CoordinatesToCellName(colIndex+1, rowIndex+1)
+				if err != nil {
+					panic(err)
+				}
+
+				line := cellName + ": " + textconvValue(value)
+				if formula != "" {
+					line += " [" + textconvValue(formula) + "]"
+				}
+				fmt.Fprintln(output, line)
+			}
+		}
+
+		fmt.Fprintln(output)
+	}
+}
+
+// keeps multi line cells on one line
+func textconvValue(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "\\n")
+	value = strings.ReplaceAll(value, "\n", "\\n")
+	return strings.ReplaceAll(value, "\r", "\\n")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+)
+
+// the settings used to diff a pair of workbooks
+type diffOptions struct {
+	primaryKeys  []string
+	formulas     bool
+	styles       bool
+	regions      regionFlags
+	smartCompare bool
+	format       string
+	context      int
+}
+
+// the old and new path of a workbook that was renamed or moved, blank if it was not
+type fileRename struct {
+	from string
+	to   string
+}
+
+// describes the two versions being compared at the top of the diff
+type diffHeader struct {
+	theirs string
+	mine   string
+	rename fileRename
+}
+
+// the differences between two workbooks
+type workbookDiff struct {
+	header             diffHeader
+	sheetChanges       []sheetChange
+	definedNameChanges []definedNameChange
+	sheets             []sheetDiff
+	counts             diffCounts
+}
+
+// the output formats and the extension of the file each one is written to.
+// Formats without an extension are printed to stdout
+var outputFormats = map[string]string{
+	"html":     ".html",
+	"json":     ".json",
+	"text":     "",
+	"markdown": ".md",
+	"xlsx":     ".xlsx",
+}
+
+// diffs the theirs workbook against the mine workbook, writes the diff to the
+// output file, or stdout when there is no output file, in the format of the
+// options and returns the number of changes found
+func diffWorkbooks(theirWorkBook string, mineWorkBook string, outputFilePath string, header diffHeader, options diffOptions) (diffCounts, error) {
+	diff, err := compareWorkbooks(theirWorkBook, mineWorkBook, header, options)
+	if err != nil {
+		return diffCounts{}, err
+	}
+
+	var output []byte
+	switch options.format {
+	case "json":
+		output = jsonWorkbookDiff(diff)
+	case "xlsx":
+		output = xlsxWorkbookDiff(diff)
+	case "markdown":
+		output = []byte(markdownWorkbookDiff(diff))
+	case "text":
+		output = []byte(textWorkbookDiff(diff, options.context, colorOutput(diffStdout)))
+	default:
+		output = []byte(htmlWorkbookDiff(diff))
+	}
+
+	if outputFilePath == "" {
+		if _, err := diffStdout.Write(output); err != nil {
+			panic(err)
+		}
+		return diff.counts, nil
+	}
+
+	if err := os.WriteFile(outputFilePath, output, 0644); err != nil {
+		panic(err)
+	}
+
+	return diff.counts, nil
+}
+
+// finds the differences between the theirs workbook and the mine workbook
+func compareWorkbooks(theirWorkBook string, mineWorkBook string, header diffHeader, options diffOptions) (workbookDiff, error) {
+	excelMine, err := openWorkbook(mineWorkBook)
+	if err != nil {
+		return workbookDiff{}, err
+	}
+
+	defer func() {
+		//close the spreadsheet
+		if err := excelMine.Close(); err != nil {
+			panic(err)
+		}
+	}()
+
+	excelTheirs, err := openWorkbook(theirWorkBook)
+
+	if err != nil {
+		return workbookDiff{}, err
+	}
+
+	defer func() {
+		//close the spreadsheet
+		if err := excelTheirs.Close(); err != nil {
+			panic(err)
+		}
+	}()
+
+	// write 'mine' sheets to a csv
+	sheetsMine = writeSheetsToCsv(excelMine, true)
+
+	// write 'theirs' sheets to a csv
+	sheetsTheirs = writeSheetsToCsv(excelTheirs, false)
+
+	// remove temp csv files
+	defer func() {
+		removeFiles(sheetsMine, true)
+		removeFiles(sheetsTheirs, false)
+	}()
+
+	structure := compareWorkbookStructure(excelTheirs, excelMine)
+
+	diff := workbookDiff{header: header, sheetChanges: structure.changes, definedNameChanges: compareDefinedNames(excelTheirs, excelMine)}
+	diff.counts = diffCounts{other: len(diff.sheetChanges) + len(diff.definedNameChanges)}
+
+	for _, pair := range structure.pairs {
+		dataMine := readSheetCsv(pair.mine, true)
+		dataTheirs := readSheetCsv(pair.theirs, false)
+
+		detailsTheirs := cellDetails{comments: readSheetComments(excelTheirs, pair.theirs), rules: readSheetRules(excelTheirs, pair.theirs)}
+		detailsMine := cellDetails{comments: readSheetComments(excelMine, pair.mine), rules: readSheetRules(excelMine, pair.mine)}
+		if options.formulas {
+			detailsTheirs.formulas = readSheetFormulas(excelTheirs, pair.theirs)
+			detailsMine.formulas = readSheetFormulas(excelMine, pair.mine)
+		}
+		if options.styles {
+			detailsTheirs.styles = readSheetStyles(excelTheirs, pair.theirs)
+			detailsMine.styles = readSheetStyles(excelMine, pair.mine)
+		}
+
+		regionSheet := pair.mine
+		if regionSheet == "" {
+			regionSheet = pair.theirs
+		}
+
+		sheet := compareCSV(dataTheirs, dataMine, detailsTheirs, detailsMine, options.primaryKeys, sheetPairName(pair), options.regions.regionFor(regionSheet), options.smartCompare)
+		sheet.theirsSheet = pair.theirs
+		sheet.mineSheet = pair.mine
+		if pair.theirs == "" || pair.mine == "" {
+			sheet.uncountHeaderRow()
+		}
+
+		diff.counts.add(sheet.counts)
+		diff.sheets = append(diff.sheets, sheet)
+	}
+
+	return diff, nil
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxSummarySheet = "Summary"
+
+// the longest sheet name excel allows
+const xlsxSheetNameLength = 31
+
+// the fills used to highlight the rows and cells of the diff
+type xlsxStyles struct {
+	header  int
+	added   int
+	removed int
+	changed int
+}
+
+var xlsxSheetNameReplacer = strings.NewReplacer(":", "_", "\\", "_", "/", "_", "?", "_", "*", "_", "[", "_", "]", "_")
+
+// writes the diff as a workbook with a summary sheet followed by one sheet for
+// each compared sheet. The rows of mine are laid out in order with the removed
+// rows where they used to be. Added rows are green, removed rows are red and
+// changed cells are yellow with the old value in a comment
+func xlsxWorkbookDiff(diff workbookDiff) []byte {
+	excelFile := excelize.NewFile()
+	defer excelFile.Close()
+
+	if err := excelFile.SetSheetName("Sheet1", xlsxSummarySheet); err != nil {
+		panic(err)
+	}
+
+	styles := newXlsxStyles(excelFile)
+
+	used := map[string]bool{strings.ToLower(xlsxSummarySheet): true}
+	var sheetNames []string
+	for _, sheet := range diff.sheets {
+		name := xlsxSheetName(sheet, used)
+		if _, err := excelFile.NewSheet(name); err != nil {
+			panic(err)
+		}
+		xlsxSheetDiff(excelFile, name, sheet, styles)
+		sheetNames = append(sheetNames, name)
+	}
+
+	xlsxSummary(excelFile, diff, sheetNames, styles)
+
+	buffer, err := excelFile.WriteToBuffer()
+	if err != nil {
+		panic(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func newXlsxStyles(excelFile *excelize.File) xlsxStyles {
+	newStyle := func(style *excelize.Style) int {
+		id, err := excelFile.NewStyle(style)
+		if err != nil {
+			panic(err)
+		}
+		return id
+	}
+	fill := func(color string) *excelize.Style {
+		return &excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{color}}}
+	}
+
+	return xlsxStyles{
+		header:  newStyle(&excelize.Style{Font: &excelize.Font{Bold: true}}),
+		added:   newStyle(fill("C6EFCE")),
+		removed: newStyle(fill("FFC7CE")),
+		changed: newStyle(fill("FFEB9C")),
+	}
+}
+
+// a name for the sheet of the diff that excel accepts and no other sheet has
+func xlsxSheetName(diff sheetDiff, used map[string]bool) string {
+	name := diff.mineSheet
+	if name == "" {
+		name = diff.theirsSheet
+	}
+	name = strings.Trim(xlsxSheetNameReplacer.Replace(name), "'")
+	if name == "" {
+		name = "Sheet"
+	}
+
+	unique := []rune(name)
+	if len(unique) > xlsxSheetNameLength {
+		unique = unique[:xlsxSheetNameLength]
+	}
+	for count := 2; used[strings.ToLower(string(unique))]; count++ {
+		suffix := []rune(" (" + strconv.Itoa(count) + ")")
+		unique = []rune(name)
+		if len(unique)+len(suffix) > xlsxSheetNameLength {
+			unique = unique[:xlsxSheetNameLength-len(suffix)]
+		}
+		unique = append(unique, suffix...)
+	}
+
+	used[strings.ToLower(string(unique))] = true
+	return string(unique)
+}
+
+func xlsxSheetDiff(excelFile *excelize.File, sheet string, diff sheetDiff, styles xlsxStyles) {
+	header := []interface{}{"Change", "Theirs Row", "Mine Row"}
+	for _, name := range diff.header {
+		header = append(header, name)
+	}
+	if err := excelFile.SetSheetRow(sheet, "A1", &header); err != nil {
+		panic(err)
+	}
+	xlsxSetRowStyle(excelFile, sheet, 1, len(header), styles.header)
+
+	if err := excelFile.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		panic(err)
+	}
+
+	row := 2
+	for _, line := range diff.mergedRows() {
+		// the header row is the header of the sheet unless it changed
+		if (line.lineType == unchanged && line.minePos == 0) || diff.sheetHeaderLine(line) {
+			continue
+		}
+
+		values := cellRow(diff.dataMine, line.minePos)
+		label := ""
+		if line.lineType != unchanged {
+			label = rowChangeLabel(line.lineType)
+		}
+		if line.lineType == deletion {
+			values = cellRow(diff.dataTheirs, line.theirsPos)
+		}
+
+		xlsxSetCell(excelFile, sheet, 1, row, label)
+		if line.theirsRow != 0 {
+			xlsxSetCell(excelFile, sheet, 2, row, strconv.Itoa(line.theirsRow))
+		}
+		if line.mineRow != 0 {
+			xlsxSetCell(excelFile, sheet, 3, row, strconv.Itoa(line.mineRow))
+		}
+		for col, value := range values {
+			xlsxSetCell(excelFile, sheet, col+4, row, value)
+		}
+
+		switch line.lineType {
+		case addition:
+			xlsxSetRowStyle(excelFile, sheet, row, len(values)+3, styles.added)
+		case deletion:
+			xlsxSetRowStyle(excelFile, sheet, row, len(values)+3, styles.removed)
+		case difference, styleChange:
+			xlsxChangedCells(excelFile, sheet, row, diff, line, styles)
+		}
+
+		row++
+	}
+
+	if err := excelFile.SetColWidth(sheet, "A", "C", 14); err != nil {
+		panic(err)
+	}
+}
+
+// highlights the cells that changed and puts the old value in a comment
+func xlsxChangedCells(excelFile *excelize.File, sheet string, row int, diff sheetDiff, line differenceLine, styles xlsxStyles) {
+	columns := max(len(cellRow(diff.dataTheirs, line.theirsPos)), len(cellRow(diff.dataMine, line.minePos)))
+
+	for col := 0; col < columns; col++ {
+		contentDiffers := cellContentDiffers(diff.dataTheirs, diff.dataMine, diff.detailsTheirs, diff.detailsMine, line.theirsPos, line.minePos, col)
+		styleDiffers := cellStyleDiffers(diff.detailsTheirs, diff.detailsMine, line.theirsPos, line.minePos, col)
+		if !contentDiffers && !styleDiffers {
+			continue
+		}
+
+		var comment []string
+		if contentDiffers {
+			comment = append(comment, "Old value: "+textCellValue(detailAt(diff.dataTheirs, line.theirsPos, col), detailAt(diff.detailsTheirs.formulas, line.theirsPos, col)))
+		}
+		if styleDiffers {
+			theirsStyle, mineStyle := styleDifference(detailAt(diff.detailsTheirs.styles, line.theirsPos, col), detailAt(diff.detailsMine.styles, line.minePos, col))
+			comment = append(comment, "Old style: "+theirsStyle, "New style: "+mineStyle)
+		}
+
+		cell := xlsxCellName(col+4, row)
+		if err := excelFile.SetCellStyle(sheet, cell, cell, styles.changed); err != nil {
+			panic(err)
+		}
+		if err := excelFile.AddComment(sheet, excelize.Comment{Cell: cell, Author: "ged", Text: strings.Join(comment, "\n")}); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// lists the changes in each sheet with a link to its sheet, then every other
+// change to the workbook
+func xlsxSummary(excelFile *excelize.File, diff workbookDiff, sheetNames []string, styles xlsxStyles) {
+	sheet := xlsxSummarySheet
+	row := 1
+
+	addRow := func(values ...interface{}) {
+		if err := excelFile.SetSheetRow(sheet, xlsxCellName(1, row), &values); err != nil {
+			panic(err)
+		}
+		row++
+	}
+
+	if diff.header.theirs != "" || diff.header.mine != "" {
+		addRow("Theirs", diff.header.theirs)
+		addRow("Mine", diff.header.mine)
+	}
+	if diff.header.rename.from != "" {
+		addRow("Renamed from", diff.header.rename.from)
+	}
+	if row > 1 {
+		xlsxSetColumnStyle(excelFile, sheet, 1, row-1, styles.header)
+		row++
+	}
+
+	addRow("Sheet", "Compared By", "Added", "Removed", "Changed", "Other")
+	xlsxSetRowStyle(excelFile, sheet, row-1, 6, styles.header)
+	for index, sheetDiff := range diff.sheets {
+		if err := excelFile.SetCellHyperLink(sheet, xlsxCellName(1, row), "'"+sheetNames[index]+"'!A1", "Location"); err != nil {
+			panic(err)
+		}
+		addRow(sheetNames[index], sheetDiff.comparedBy(), sheetDiff.counts.added, sheetDiff.counts.removed, sheetDiff.counts.changed, sheetDiff.counts.other)
+	}
+	addRow("Total", "", diff.counts.added, diff.counts.removed, diff.counts.changed, diff.counts.other)
+	xlsxSetRowStyle(excelFile, sheet, row-1, 6, styles.header)
+
+	var changes [][]interface{}
+	for _, change := range diff.sheetChanges {
+		changes = append(changes, []interface{}{"Workbook", textSheetChange(change)})
+	}
+	for _, change := range diff.definedNameChanges {
+		name := definedNameChangeName(change)
+		changes = append(changes, []interface{}{"Workbook", definedNameChangeString(change) + ": " + name.Name + " " + textChangedValue(change.theirs.RefersTo, change.mine.RefersTo, change.changeType)})
+	}
+	for index, sheetDiff := range diff.sheets {
+		if sheetDiff.fallbackReason != "" {
+			changes = append(changes, []interface{}{sheetNames[index], "Rows compared in order: " + sheetDiff.fallbackReason})
+		}
+		for _, change := range sheetDiff.otherChanges() {
+			changes = append(changes, []interface{}{sheetNames[index], change})
+		}
+	}
+
+	if len(changes) > 0 {
+		row++
+		addRow("Sheet", "Change")
+		xlsxSetRowStyle(excelFile, sheet, row-1, 2, styles.header)
+		for _, change := range changes {
+			addRow(change...)
+		}
+	}
+
+	if err := excelFile.SetColWidth(sheet, "A", "B", 24); err != nil {
+		panic(err)
+	}
+}
+
+// numbers are written as numbers so they can be summed and sorted in excel
+func xlsxSetCell(excelFile *excelize.File, sheet string, col int, row int, value string) {
+	if value == "" {
+		return
+	}
+
+	var cellValue interface{} = value
+	if number, err := strconv.ParseFloat(value, 64); err == nil && strconv.FormatFloat(number, 'f', -1, 64) == value {
+		cellValue = number
+	}
+
+	if err := excelFile.SetCellValue(sheet, xlsxCellName(col, row), cellValue); err != nil {
+		panic(err)
+	}
+}
+
+func xlsxSetRowStyle(excelFile *excelize.File, sheet string, row int, columns int, style int) {
+	if columns == 0 {
+		return
+	}
+	if err := excelFile.SetCellStyle(sheet, xlsxCellName(1, row), xlsxCellName(columns, row), style); err != nil {
+		panic(err)
+	}
+}
+
+func xlsxSetColumnStyle(excelFile *excelize.File, sheet string, col int, rows int, style int) {
+	if err := excelFile.SetCellStyle(sheet, xlsxCellName(col, 1), xlsxCellName(col, rows), style); err != nil {
+		panic(err)
+	}
+}
+
+func xlsxCellName(col int, row int) string {
+	cell, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		panic(err)
+	}
+	return cell
+}
+
+// the row at the position, nil when the row is not on that side
+func cellRow(data [][]string, pos int) []string {
+	if pos < 0 || pos >= len(data) {
+		return nil
+	}
+	return data[pos]
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// compares the rows of a sheet like compareWorkbooks does. A blank sheet name
+// is a sheet that is not in that workbook
+func newTestSheetDiff(theirsSheet string, mineSheet string, dataTheirs [][]string, dataMine [][]string, primaryKeys []string, smartCompare bool) sheetDiff {
+	name := mineSheet
+	if name == "" {
+		name = theirsSheet
+	}
+
+	diff := compareCSV(dataTheirs, dataMine, cellDetails{}, cellDetails{}, primaryKeys, name, nil, smartCompare)
+	diff.theirsSheet = theirsSheet
+	diff.mineSheet = mineSheet
+	if theirsSheet == "" || mineSheet == "" {
+		diff.uncountHeaderRow()
+	}
+	return diff
+}
+
+// the fill color of a cell, blank when it has none
+func xlsxTestFill(t *testing.T, excelFile *excelize.File, sheet string, cell string) string {
+	t.Helper()
+
+	styleID, err := excelFile.GetCellStyle(sheet, cell)
+	if err != nil {
+		t.Fatal(err)
+	}
+	style, err := excelFile.GetStyle(styleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(style.Fill.Color) == 0 {
+		return ""
+	}
+	return style.Fill.Color[0]
+}
+
+func TestXlsxWorkbookDiff(t *testing.T) {
+	diff := workbookDiff{header: diffHeader{theirs: "theirs.xlsx", mine: "mine.xlsx"}}
+	diff.sheets = []sheetDiff{
+		newTestSheetDiff("Data", "Data",
+			[][]string{{"ID", "Name"}, {"1", "a"}, {"2", "b"}, {"3", "c"}},
+			[][]string{{"ID", "Name"}, {"1", "aa"}, {"3", "c"}, {"4", "d"}}, []string{"ID"}, true),
+		newTestSheetDiff("Gone", "",
+			[][]string{{"ID", "Qty"}, {"1", "5"}}, nil, nil, true),
+		newTestSheetDiff("Header", "Header",
+			[][]string{{"A", "B"}, {"1", "2"}},
+			[][]string{{"A", "C"}, {"1", "2"}}, nil, false),
+	}
+	for _, sheet := range diff.sheets {
+		diff.counts.add(sheet.counts)
+	}
+
+	excelFile, err := excelize.OpenReader(bytes.NewReader(xlsxWorkbookDiff(diff)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer excelFile.Close()
+
+	if sheets := excelFile.GetSheetList(); !reflect.DeepEqual(sheets, []string{xlsxSummarySheet, "Data", "Gone", "Header"}) {
+		t.Fatalf("sheets %q", sheets)
+	}
+
+	tests := []struct {
+		sheet    string
+		rows     [][]string
+		fills    map[string]string
+		comments map[string]string
+	}{
+		{
+			// rows of mine in order with the removed row where it used to be
+			sheet: "Data",
+			rows: [][]string{
+				{"Change", "Theirs Row", "Mine Row", "ID", "Name"},
+				{"Row changed", "2", "2", "1", "aa"},
+				{"Row removed", "3", "", "2", "b"},
+				{"", "4", "3", "3", "c"},
+				{"Row added", "", "4", "4", "d"},
+			},
+			fills:    map[string]string{"D2": "", "E2": "FFEB9C", "A3": "FFC7CE", "E3": "FFC7CE", "E4": "", "A5": "C6EFCE", "E5": "C6EFCE"},
+			comments: map[string]string{"E2": "Old value: a"},
+		},
+		{
+			// the header of a removed sheet is the header of the diff, not a removed row
+			sheet: "Gone",
+			rows: [][]string{
+				{"Change", "Theirs Row", "Mine Row", "ID", "Qty"},
+				{"Row removed", "2", "", "1", "5"},
+			},
+			fills: map[string]string{"A2": "FFC7CE", "E2": "FFC7CE"},
+		},
+		{
+			// a changed header row is listed with the old names in comments
+			sheet: "Header",
+			rows: [][]string{
+				{"Change", "Theirs Row", "Mine Row", "A", "C"},
+				{"Row changed", "1", "1", "A", "C"},
+				{"", "2", "2", "1", "2"},
+			},
+			fills:    map[string]string{"D2": "", "E2": "FFEB9C"},
+			comments: map[string]string{"E2": "Old value: B"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.sheet, func(t *testing.T) {
+			if rows := mergedRows(t, excelFile, test.sheet); !reflect.DeepEqual(rows, test.rows) {
+				t.Errorf("rows %q, want %q", rows, test.rows)
+			}
+
+			for cell, fill := range test.fills {
+				if got := xlsxTestFill(t, excelFile, test.sheet, cell); got != fill {
+					t.Errorf("%s fill %q, want %q", cell, got, fill)
+				}
+			}
+
+			comments, err := excelFile.GetComments(test.sheet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for _, comment := range comments {
+				got[comment.Cell] = comment.Text
+			}
+			if len(got) == 0 {
+				got = nil
+			}
+			if !reflect.DeepEqual(got, test.comments) {
+				t.Errorf("comments %q, want %q", got, test.comments)
+			}
+		})
+	}
+
+	// the summary counts leave the header of the removed sheet out
+	summary := mergedRows(t, excelFile, xlsxSummarySheet)
+	if want := []string{"Gone", "row order", "0", "1", "0", "0"}; !reflect.DeepEqual(summary[5], want) {
+		t.Errorf("summary row %q, want %q", summary[5], want)
+	}
+}